@@ -0,0 +1,135 @@
+package trie
+
+import (
+	"time"
+)
+
+// GetOrInsert returns the value stored under key if there is one that has not expired. Otherwise it inserts value without an
+// expiry time and returns it. loaded reports whether the value was already present.
+func (t *Tree[T]) GetOrInsert(key string, value T) (actual T, loaded bool) {
+	return t.getOrInsert(key, value, 0, false)
+}
+
+// GetOrInsertWithExpiry is like GetOrInsert, but an inserted value expires after the given duration. The expiry time of a
+// value that is already present is left unchanged.
+func (t *Tree[T]) GetOrInsertWithExpiry(key string, value T, expiry time.Duration) (actual T, loaded bool) {
+	return t.getOrInsert(key, value, expiry, true)
+}
+
+// CompareAndSwap replaces the value stored under key with new if the stored value has not expired and eq reports it equal
+// to old. The entry keeps its expiry time and sliding expiry. It reports whether the value was swapped.
+func (t *Tree[T]) CompareAndSwap(key string, old, new T, eq func(a, b T) bool) (swapped bool) {
+	t.update(key, func(current *valueWithExpiry[T], _ time.Time) (*valueWithExpiry[T], bool) {
+		if current == nil || !eq(current.value, old) {
+			return nil, false
+		}
+		swapped = true
+		return &valueWithExpiry[T]{value: new, expiry: current.expiry, sliding: current.sliding}, true
+	})
+	return swapped
+}
+
+// CompareAndDelete removes the value stored under key if it has not expired and eq reports it equal to old. It reports
+// whether the value was removed.
+func (t *Tree[T]) CompareAndDelete(key string, old T, eq func(a, b T) bool) (deleted bool) {
+	t.update(key, func(current *valueWithExpiry[T], _ time.Time) (*valueWithExpiry[T], bool) {
+		if current == nil || !eq(current.value, old) {
+			return nil, false
+		}
+		deleted = true
+		return nil, true
+	})
+	return deleted
+}
+
+// Update calls fn with the value stored under key, or with exists set to false if there is none or it has expired, and
+// stores the value fn returns. If fn returns keep set to false, the key is removed instead. An updated value keeps the expiry
+// time of the value it replaces, while a newly inserted one does not expire. Update returns what fn returned.
+//
+// fn is called while the Tree is locked, so it must not use the Tree.
+func (t *Tree[T]) Update(key string, fn func(old T, exists bool) (value T, keep bool)) (value T, kept bool) {
+	t.update(key, func(current *valueWithExpiry[T], _ time.Time) (*valueWithExpiry[T], bool) {
+		if current == nil {
+			value, kept = fn(*new(T), false)
+			if !kept {
+				return nil, false
+			}
+			return &valueWithExpiry[T]{value: value}, true
+		}
+		value, kept = fn(current.value, true)
+		if !kept {
+			return nil, true
+		}
+		return &valueWithExpiry[T]{value: value, expiry: current.expiry, sliding: current.sliding}, true
+	})
+	return value, kept
+}
+
+// getOrInsert implements GetOrInsert and, if withExpiry is set, GetOrInsertWithExpiry.
+func (t *Tree[T]) getOrInsert(key string, value T, expiry time.Duration, withExpiry bool) (actual T, loaded bool) {
+	t.update(key, func(current *valueWithExpiry[T], now time.Time) (*valueWithExpiry[T], bool) {
+		if current != nil {
+			actual, loaded = current.value, true
+			t.touched(key)
+			return nil, false
+		}
+		actual = value
+		entry := &valueWithExpiry[T]{value: value}
+		if withExpiry {
+			expiryTime := now.Add(expiry)
+			entry.expiry = &expiryTime
+		}
+		return entry, true
+	})
+	return actual, loaded
+}
+
+// update runs a read-modify-write of the entry stored under key under a single acquisition of the write lock. fn is called
+// with the current entry, or nil if there is none or it is expired at now. If fn returns write set to true, its entry
+// replaces the current one, or the key is removed if the entry is nil. An expired entry that is not replaced is removed.
+// Entries are never modified in place, since snapshots may share them. The replaced or removed entry is reported to the
+// OnEvict callback after the lock is released.
+func (t *Tree[T]) update(key string, fn func(current *valueWithExpiry[T], now time.Time) (next *valueWithExpiry[T], write bool)) {
+	if t.syncSafe {
+		t.lock.Lock()
+	}
+	now := t.now()
+	var stored *valueWithExpiry[T]
+	if node := t.root.descend(key); node != nil && node.isEnd {
+		stored = node.value
+	}
+	current := stored
+	if current != nil && current.expired(now) {
+		current = nil
+	}
+	next, write := fn(current, now)
+
+	var old *valueWithExpiry[T]
+	var evictions []eviction[T]
+	reason := EvictReplaced
+	switch {
+	case write && next != nil:
+		evictions = t.makeRoom(key, next)
+		var target *node[T]
+		var created int
+		t.root, target, created = t.root.insert(t.gen, key)
+		t.nodes += created
+		old = target.value
+		target.setEntry(next)
+		t.remember(key, old, next)
+	case stored != nil && (write || current == nil):
+		// the value is removed on request, or because it has expired
+		var pruned int
+		t.root, old, pruned = t.root.remove(t.gen, key, nil)
+		t.forget(key, old, pruned)
+		reason = EvictRemoved
+	}
+	evictions = append(evictions, t.shrink()...)
+	if t.syncSafe {
+		t.lock.Unlock()
+	}
+	if old != nil {
+		t.evicted(key, old, now, reason)
+	}
+	t.evictedAll(evictions, now)
+}
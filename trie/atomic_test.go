@@ -0,0 +1,177 @@
+package trie
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func equalInts(a, b int) bool {
+	return a == b
+}
+
+func TestGetOrInsert(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[int](WithClock(clock))
+
+	actual, loaded := trie.GetOrInsert("key", 1)
+	if loaded || actual != 1 {
+		t.Errorf("expected loaded=false, actual=1, got loaded=%v, actual=%d", loaded, actual)
+	}
+	actual, loaded = trie.GetOrInsert("key", 2)
+	if !loaded || actual != 1 {
+		t.Errorf("expected loaded=true, actual=1, got loaded=%v, actual=%d", loaded, actual)
+	}
+
+	// An expired value counts as absent
+	trie.GetOrInsertWithExpiry("temp", 1, time.Minute)
+	clock.Advance(2 * time.Minute)
+	actual, loaded = trie.GetOrInsertWithExpiry("temp", 2, time.Minute)
+	if loaded || actual != 2 {
+		t.Errorf("expected loaded=false, actual=2, got loaded=%v, actual=%d", loaded, actual)
+	}
+	clock.Advance(2 * time.Minute)
+	if _, found := trie.Find("temp"); found {
+		t.Errorf("expected the inserted value to expire")
+	}
+	if trie.Len() != 1 {
+		t.Errorf("expected len=1, got len=%d", trie.Len())
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[int](WithClock(clock))
+
+	if trie.CompareAndSwap("missing", 0, 1, equalInts) {
+		t.Errorf("expected no swap for a missing key")
+	}
+	if _, found := trie.Find("missing"); found {
+		t.Errorf("expected missing to stay missing")
+	}
+
+	trie.InsertWithExpiry("key", 1, time.Minute)
+	if trie.CompareAndSwap("key", 2, 3, equalInts) {
+		t.Errorf("expected no swap for a different value")
+	}
+	if !trie.CompareAndSwap("key", 1, 3, equalInts) {
+		t.Errorf("expected a swap for an equal value")
+	}
+	if value, _ := trie.Find("key"); value != 3 {
+		t.Errorf("expected value=3, got value=%d", value)
+	}
+
+	// The swapped value keeps the expiry time
+	clock.Advance(2 * time.Minute)
+	if trie.CompareAndSwap("key", 3, 4, equalInts) {
+		t.Errorf("expected no swap for an expired value")
+	}
+	if trie.Len() != 0 {
+		t.Errorf("expected the expired value to be removed, got len=%d", trie.Len())
+	}
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	log := &evictionLog{}
+	trie := NewTree[string](WithOnEvict(log.record))
+	equal := func(a, b string) bool { return a == b }
+
+	trie.Insert("key", "value")
+	if trie.CompareAndDelete("key", "other", equal) {
+		t.Errorf("expected no delete for a different value")
+	}
+	if !trie.CompareAndDelete("key", "value", equal) {
+		t.Errorf("expected a delete for an equal value")
+	}
+	if trie.Len() != 0 || countNodes(trie.root) != 1 {
+		t.Errorf("expected an empty Trie, got len=%d and %d nodes", trie.Len(), countNodes(trie.root))
+	}
+	if events := log.take(); !reflect.DeepEqual(events, []string{"key=value:removed"}) {
+		t.Errorf("expected [key=value:removed], got %v", events)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	log := &evictionLog{}
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock), WithOnEvict(log.record))
+	appendA := func(old string, exists bool) (string, bool) {
+		return old + "a", true
+	}
+
+	// Insert, then update in place
+	if value, kept := trie.Update("key", appendA); !kept || value != "a" {
+		t.Errorf("expected kept=true, value='a', got kept=%v, value=%v", kept, value)
+	}
+	trie.Update("key", appendA)
+	if value, _ := trie.Find("key"); value != "aa" {
+		t.Errorf("expected value='aa', got value=%v", value)
+	}
+	if events := log.take(); !reflect.DeepEqual(events, []string{"key=a:replaced"}) {
+		t.Errorf("expected [key=a:replaced], got %v", events)
+	}
+
+	// Remove by not keeping the value
+	trie.Update("key", func(old string, exists bool) (string, bool) {
+		if !exists || old != "aa" {
+			t.Errorf("expected exists=true, old='aa', got exists=%v, old=%v", exists, old)
+		}
+		return "", false
+	})
+	if trie.Len() != 0 {
+		t.Errorf("expected len=0, got len=%d", trie.Len())
+	}
+	if events := log.take(); !reflect.DeepEqual(events, []string{"key=aa:removed"}) {
+		t.Errorf("expected [key=aa:removed], got %v", events)
+	}
+
+	// An expired value is reported as absent, and the update keeps no expiry time
+	trie.InsertWithExpiry("temp", "old", time.Minute)
+	clock.Advance(2 * time.Minute)
+	trie.Update("temp", func(old string, exists bool) (string, bool) {
+		if exists {
+			t.Errorf("expected an expired value to be absent")
+		}
+		return "new", true
+	})
+	if events := log.take(); !reflect.DeepEqual(events, []string{"temp=old:expired"}) {
+		t.Errorf("expected [temp=old:expired], got %v", events)
+	}
+	clock.Advance(time.Hour)
+	if value, found := trie.Find("temp"); !found || value != "new" {
+		t.Errorf("expected found=true, value='new', got found=%v, value=%v", found, value)
+	}
+}
+
+func TestUpdateDoesNotChangeSnapshots(t *testing.T) {
+	trie := NewTree[int]()
+	trie.Insert("counter", 1)
+	snapshot := trie.Snapshot()
+	trie.Update("counter", func(old int, _ bool) (int, bool) {
+		return old + 1, true
+	})
+	if value, _ := snapshot.Find("counter"); value != 1 {
+		t.Errorf("expected the snapshot to keep value=1, got value=%d", value)
+	}
+}
+
+func TestUpdateConcurrentCounter(t *testing.T) {
+	trie := NewConcurrentTree[int]()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				trie.Update("counter", func(old int, _ bool) (int, bool) {
+					return old + 1, true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	if value, _ := trie.Find("counter"); value != 8000 {
+		t.Errorf("expected counter=8000, got counter=%d", value)
+	}
+}
@@ -0,0 +1,274 @@
+package trie
+
+import (
+	"fmt"
+	"time"
+)
+
+// capacity bounds the number of values, or their estimated size, that a Tree holds.
+type capacity[T any] struct {
+	policy     EvictionPolicy
+	newPolicy  func() EvictionPolicy
+	maxEntries int
+	maxBytes   int64
+	sizeOf     func(key string, value T) int64
+	bytes      int64 // estimated size of the stored values, guarded by the Tree's write lock
+	// earliest is no later than the expiry time of any stored value, or zero if no stored value expires. Until it has passed,
+	// no value can have expired, so there is nothing to reclaim before evicting. It is guarded by the Tree's write lock.
+	earliest time.Time
+}
+
+// eviction is a value removed from the Tree that still has to be reported to the OnEvict callback.
+type eviction[T any] struct {
+	key   string
+	entry *valueWithExpiry[T]
+}
+
+// WithMaxEntries bounds the number of values a Tree holds. Once an insert takes the Tree over n values, values chosen by
+// the eviction policy (least recently used unless WithEvictionPolicy is given) are evicted and reported to the OnEvict
+// callback with EvictCapacity. Expired values are reclaimed first, and reported with EvictExpired, so live values are only
+// evicted while the Tree is full of live values. A ShardedTree bounds each of its 256 shards separately, so it holds up to
+// 256 times n values. It panics if n is less than 1.
+func WithMaxEntries(n int) Option {
+	if n < 1 {
+		panic(fmt.Sprintf("trie: maximum number of entries must be at least 1, got %d", n))
+	}
+	return func(o *options) {
+		o.set("WithMaxEntries", scopeTree)
+		o.maxEntries = n
+	}
+}
+
+// WithMaxBytes bounds the estimated size of the values a Tree holds, as computed by sizeOf for every stored key-value pair.
+// Values are evicted as with WithMaxEntries once an insert takes the Tree over max bytes, which may evict the inserted value
+// itself if it alone is larger than max. The value type of sizeOf must match the value type of the Tree, otherwise the
+// constructor panics.
+func WithMaxBytes[T any](max int64, sizeOf func(key string, value T) int64) Option {
+	if max < 1 {
+		panic(fmt.Sprintf("trie: maximum number of bytes must be at least 1, got %d", max))
+	}
+	return func(o *options) {
+		o.set("WithMaxBytes", scopeTree)
+		o.maxBytes = max
+		o.sizeOf = sizeOf
+	}
+}
+
+// WithEvictionPolicy makes a capacity-bounded Tree choose the values to evict with a policy created by newPolicy, such as
+// NewLRUPolicy or NewLFUPolicy. Every Tree created with the option gets a policy of its own.
+func WithEvictionPolicy(newPolicy func() EvictionPolicy) Option {
+	return func(o *options) {
+		o.set("WithEvictionPolicy", scopeTree)
+		o.newPolicy = newPolicy
+	}
+}
+
+// capacityFor returns the capacity configured in o for a Tree of T, or nil if the Tree is unbounded.
+func capacityFor[T any](o *options) *capacity[T] {
+	if o.maxEntries == 0 && o.maxBytes == 0 {
+		return nil
+	}
+	c := &capacity[T]{newPolicy: o.newPolicy, maxEntries: o.maxEntries, maxBytes: o.maxBytes}
+	if c.newPolicy == nil {
+		c.newPolicy = NewLRUPolicy
+	}
+	if o.maxBytes > 0 {
+		sizeOf, ok := o.sizeOf.(func(key string, value T) int64)
+		if !ok {
+			panic("trie: WithMaxBytes size function does not match the value type of the tree")
+		}
+		c.sizeOf = sizeOf
+	}
+	c.policy = c.newPolicy()
+	return c
+}
+
+// size returns the estimated size of entry stored under key.
+func (c *capacity[T]) size(key string, entry *valueWithExpiry[T]) int64 {
+	if c.sizeOf == nil {
+		return 0
+	}
+	return c.sizeOf(key, entry.value)
+}
+
+// over reports whether values values of the given estimated size exceed the capacity.
+func (c *capacity[T]) over(values int, bytes int64) bool {
+	return (c.maxEntries > 0 && values > c.maxEntries) || (c.maxBytes > 0 && bytes > c.maxBytes)
+}
+
+// remember updates the size counters and the eviction policy after entry was stored under key in place of old, which is nil
+// if key held no value, and notifies the watchers of key. It must be called while holding the write lock.
+func (t *Tree[T]) remember(key string, old, entry *valueWithExpiry[T]) {
+	if old == nil {
+		t.values++
+	}
+	t.notify(EventPut, key, entry.value)
+	if t.bound == nil {
+		return
+	}
+	t.bound.noteExpiry(entry)
+	if old == nil {
+		t.bound.policy.Inserted(key)
+	} else {
+		t.bound.bytes -= t.bound.size(key, old)
+		t.bound.policy.Accessed(key)
+	}
+	t.bound.bytes += t.bound.size(key, entry)
+}
+
+// dropped tells the eviction policy and the watchers of key that the value old stored under key has been removed. It must be
+// called while holding the write lock.
+func (t *Tree[T]) dropped(key string, old *valueWithExpiry[T]) {
+	t.notifyRemoved(key, old)
+	if t.bound == nil {
+		return
+	}
+	t.bound.bytes -= t.bound.size(key, old)
+	t.bound.policy.Removed(key)
+}
+
+// noteExpiry lowers the earliest expiry time to the expiry time of entry, which has just been stored, if it is earlier. c may
+// be nil.
+func (c *capacity[T]) noteExpiry(entry *valueWithExpiry[T]) {
+	if c == nil || entry.expiry == nil {
+		return
+	}
+	if c.earliest.IsZero() || entry.expiry.Before(c.earliest) {
+		c.earliest = *entry.expiry
+	}
+}
+
+// touched tells the eviction policy that the value stored under key has been found. It must be called while holding the lock,
+// for reading at least.
+func (t *Tree[T]) touched(key string) {
+	if t.bound != nil {
+		t.bound.policy.Accessed(key)
+	}
+}
+
+// makeRoom evicts the values chosen by the eviction policy until storing entry under key keeps the Tree within its capacity,
+// so the new value does not compete with the values it displaces. Expired values are reclaimed first, so live values are only
+// evicted if that is not enough. It stops early if the policy picks the value entry replaces. It returns the evicted values
+// so they can be reported once the lock is released. It must be called while holding the write lock.
+func (t *Tree[T]) makeRoom(key string, entry *valueWithExpiry[T]) []eviction[T] {
+	if t.bound == nil {
+		return nil
+	}
+	values, bytes := t.growth(key, entry)
+	evictions := t.reclaim(values, bytes)
+	if evictions != nil {
+		// the value entry replaces may have been reclaimed
+		values, bytes = t.growth(key, entry)
+	}
+	return append(evictions, t.evict(values, bytes, func(victim string) bool {
+		return victim != key
+	})...)
+}
+
+// growth returns how many values and bytes storing entry under key adds to the Tree.
+func (t *Tree[T]) growth(key string, entry *valueWithExpiry[T]) (values int, bytes int64) {
+	bytes = t.bound.size(key, entry)
+	if node := t.root.descend(key); node != nil && node.isEnd {
+		return 0, bytes - t.bound.size(key, node.value)
+	}
+	return 1, bytes
+}
+
+// shrink evicts the values chosen by the eviction policy until the Tree is within its capacity, after reclaiming the expired
+// values, and returns them so they can be reported once the lock is released. It must be called while holding the write lock.
+func (t *Tree[T]) shrink() []eviction[T] {
+	if t.bound == nil {
+		return nil
+	}
+	evictions := t.reclaim(0, 0)
+	return append(evictions, t.evict(0, 0, func(string) bool {
+		return true
+	})...)
+}
+
+// reclaim removes the expired values if the Tree would exceed its capacity with extraValues more values of extraBytes more
+// bytes and a value may have expired, and returns them so they can be reported once the lock is released. It must be called
+// while holding the write lock.
+func (t *Tree[T]) reclaim(extraValues int, extraBytes int64) []eviction[T] {
+	now := t.now()
+	if t.bound.earliest.IsZero() || now.Before(t.bound.earliest) || !t.bound.over(t.values+extraValues, t.bound.bytes+extraBytes) {
+		return nil
+	}
+	var evictions []eviction[T]
+	var removed, pruned int
+	t.root, removed, pruned = t.root.sweep(t.gen, make([]byte, 0, 64), now, func(key []byte, entry *valueWithExpiry[T]) {
+		t.dropped(string(key), entry)
+		evictions = append(evictions, eviction[T]{key: string(key), entry: entry})
+	})
+	t.values -= removed
+	t.nodes -= pruned
+	t.bound.earliest = time.Time{}
+	t.root.walkEntries(make([]byte, 0, 64), time.Time{}, func(_ []byte, entry *valueWithExpiry[T]) bool {
+		t.bound.noteExpiry(entry)
+		return true
+	})
+	return evictions
+}
+
+// evict evicts the values chosen by the eviction policy while the Tree would exceed its capacity with extraValues more values
+// of extraBytes more bytes, or until allow rejects a victim. It must be called while holding the write lock.
+func (t *Tree[T]) evict(extraValues int, extraBytes int64, allow func(victim string) bool) []eviction[T] {
+	var evictions []eviction[T]
+	for t.bound.over(t.values+extraValues, t.bound.bytes+extraBytes) {
+		key, ok := t.bound.policy.Victim()
+		if !ok || !allow(key) {
+			break
+		}
+		var old *valueWithExpiry[T]
+		var pruned int
+		t.root, old, pruned = t.root.remove(t.gen, key, nil)
+		if old == nil {
+			// the policy tracks a key the Tree does not hold
+			t.bound.policy.Removed(key)
+			continue
+		}
+		t.forget(key, old, pruned)
+		evictions = append(evictions, eviction[T]{key: key, entry: old})
+	}
+	return evictions
+}
+
+// reset makes the eviction policy track exactly the values stored in the Tree, after its contents were replaced. It must be
+// called while holding the write lock.
+func (t *Tree[T]) reset() {
+	if t.bound == nil {
+		return
+	}
+	t.bound.policy = t.bound.newPolicy()
+	t.bound.bytes = 0
+	t.bound.earliest = time.Time{}
+	// no value has expired at the zero time, so every stored value is visited
+	t.root.walkEntries(make([]byte, 0, 64), time.Time{}, func(key []byte, entry *valueWithExpiry[T]) bool {
+		t.bound.policy.Inserted(string(key))
+		t.bound.bytes += t.bound.size(string(key), entry)
+		t.bound.noteExpiry(entry)
+		return true
+	})
+}
+
+// evictedAll reports the values evicted to keep the Tree within its capacity to the OnEvict callback. It must be called
+// without holding the lock.
+func (t *Tree[T]) evictedAll(evictions []eviction[T], now time.Time) {
+	for _, e := range evictions {
+		t.evicted(e.key, e.entry, now, EvictCapacity)
+	}
+}
+
+// clone returns a capacity with the same bounds as c that tracks no values yet, or nil if c is nil.
+func (c *capacity[T]) clone() *capacity[T] {
+	if c == nil {
+		return nil
+	}
+	return &capacity[T]{
+		policy:     c.newPolicy(),
+		newPolicy:  c.newPolicy,
+		maxEntries: c.maxEntries,
+		maxBytes:   c.maxBytes,
+		sizeOf:     c.sizeOf,
+	}
+}
@@ -0,0 +1,254 @@
+package trie
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaxEntriesLRU(t *testing.T) {
+	log := &evictionLog{}
+	trie := NewTree[string](WithMaxEntries(3), WithOnEvict(log.record))
+	trie.Insert("a", "1")
+	trie.Insert("b", "2")
+	trie.Insert("c", "3")
+
+	// Finding a makes b the least recently used key
+	trie.Find("a")
+	trie.Insert("d", "4")
+	if events := log.take(); !reflect.DeepEqual(events, []string{"b=2:capacity"}) {
+		t.Errorf("expected [b=2:capacity], got %v", events)
+	}
+	if keys := trie.KeysWithPrefix("", 0); !reflect.DeepEqual(keys, []string{"a", "c", "d"}) {
+		t.Errorf("expected [a c d], got %v", keys)
+	}
+
+	// Replacing a value counts as a use and does not grow the Tree
+	trie.Insert("c", "5")
+	trie.Insert("e", "6")
+	if events := log.take(); !reflect.DeepEqual(events, []string{"c=3:replaced", "a=1:capacity"}) {
+		t.Errorf("expected [c=3:replaced a=1:capacity], got %v", events)
+	}
+	if trie.Len() != 3 {
+		t.Errorf("expected len=3, got len=%d", trie.Len())
+	}
+}
+
+func TestMaxEntriesLFU(t *testing.T) {
+	log := &evictionLog{}
+	trie := NewTree[string](WithMaxEntries(2), WithEvictionPolicy(NewLFUPolicy), WithOnEvict(log.record))
+	trie.Insert("hot", "1")
+	trie.Insert("cold", "2")
+	for i := 0; i < 3; i++ {
+		trie.Find("hot")
+	}
+	trie.Find("cold")
+
+	trie.Insert("new", "3")
+	if events := log.take(); !reflect.DeepEqual(events, []string{"cold=2:capacity"}) {
+		t.Errorf("expected [cold=2:capacity], got %v", events)
+	}
+
+	// Among equally used keys, the least recently used one goes first
+	trie.Insert("newer", "4")
+	if events := log.take(); !reflect.DeepEqual(events, []string{"new=3:capacity"}) {
+		t.Errorf("expected [new=3:capacity], got %v", events)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	log := &evictionLog{}
+	sizeOf := func(key string, value string) int64 {
+		return int64(len(key) + len(value))
+	}
+	trie := NewTree[string](WithMaxBytes(10, sizeOf), WithOnEvict(log.record))
+	trie.Insert("a", "1234")
+	trie.Insert("b", "1234")
+	if events := log.take(); len(events) != 0 {
+		t.Errorf("expected no evictions within capacity, got %v", events)
+	}
+	trie.Insert("c", "12")
+	if events := log.take(); !reflect.DeepEqual(events, []string{"a=1234:capacity"}) {
+		t.Errorf("expected [a=1234:capacity], got %v", events)
+	}
+
+	// A value larger than the capacity evicts everything, including itself
+	trie.Insert("huge", "12345678")
+	if trie.Len() != 0 {
+		t.Errorf("expected len=0, got len=%d", trie.Len())
+	}
+	if trie.bound.bytes != 0 {
+		t.Errorf("expected bytes=0, got bytes=%d", trie.bound.bytes)
+	}
+}
+
+func TestCapacityExpiry(t *testing.T) {
+	log := &evictionLog{}
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock), WithMaxEntries(2), WithOnEvict(log.record))
+	trie.InsertWithExpiry("temp", "1", time.Minute)
+	trie.Insert("keep", "2")
+	trie.Find("temp")
+
+	// An evicted value that had already expired is reported as expired
+	clock.Advance(time.Hour)
+	trie.Insert("keep", "3")
+	trie.Insert("new", "4")
+	if events := log.take(); !reflect.DeepEqual(events, []string{"keep=2:replaced", "temp=1:expired"}) {
+		t.Errorf("expected [keep=2:replaced temp=1:expired], got %v", events)
+	}
+
+	// Swept values leave room for new ones
+	trie.InsertWithExpiry("new", "5", -time.Second)
+	trie.Sweep()
+	log.take()
+	trie.Insert("other", "6")
+	if events := log.take(); len(events) != 0 {
+		t.Errorf("expected no evictions after a sweep, got %v", events)
+	}
+	if keys := trie.KeysWithPrefix("", 0); !reflect.DeepEqual(keys, []string{"keep", "other"}) {
+		t.Errorf("expected [keep other], got %v", keys)
+	}
+}
+
+func TestCapacityReclaimsExpired(t *testing.T) {
+	log := &evictionLog{}
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock), WithMaxEntries(2), WithOnEvict(log.record))
+	trie.Insert("keep", "1")
+	trie.InsertWithExpiry("temp", "2", time.Minute)
+
+	// keep is the least recently used value, but the expired temp goes first
+	clock.Advance(time.Hour)
+	trie.Insert("new", "3")
+	if events := log.take(); !reflect.DeepEqual(events, []string{"temp=2:expired"}) {
+		t.Errorf("expected [temp=2:expired], got %v", events)
+	}
+	if keys := trie.KeysWithPrefix("", 0); !reflect.DeepEqual(keys, []string{"keep", "new"}) {
+		t.Errorf("expected [keep new], got %v", keys)
+	}
+	if trie.Len() != 2 {
+		t.Errorf("expected len=2, got len=%d", trie.Len())
+	}
+
+	// once nothing has expired, the least recently used value is evicted
+	trie.Insert("last", "4")
+	if events := log.take(); !reflect.DeepEqual(events, []string{"keep=1:capacity"}) {
+		t.Errorf("expected [keep=1:capacity], got %v", events)
+	}
+}
+
+func TestCapacityRemoveAndUpdate(t *testing.T) {
+	trie := NewTree[int](WithMaxEntries(2))
+	trie.Insert("a", 1)
+	trie.Insert("b", 2)
+	trie.Remove("a")
+	trie.GetOrInsert("c", 3)
+	if keys := trie.KeysWithPrefix("", 0); !reflect.DeepEqual(keys, []string{"b", "c"}) {
+		t.Errorf("expected [b c], got %v", keys)
+	}
+	trie.Update("d", func(int, bool) (int, bool) {
+		return 4, true
+	})
+	if keys := trie.KeysWithPrefix("", 0); !reflect.DeepEqual(keys, []string{"c", "d"}) {
+		t.Errorf("expected [c d], got %v", keys)
+	}
+}
+
+func TestCapacityReadFrom(t *testing.T) {
+	source := NewTree[int]()
+	for i := 0; i < 5; i++ {
+		source.Insert(fmt.Sprint(i), i)
+	}
+	data, err := source.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	restored := NewTree[int](WithMaxEntries(3))
+	restored.Insert("old", -1)
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restored.Len() != 3 {
+		t.Errorf("expected len=3, got len=%d", restored.Len())
+	}
+	restored.Insert("new", 5)
+	if restored.Len() != 3 {
+		t.Errorf("expected len=3, got len=%d", restored.Len())
+	}
+}
+
+func TestCapacityConcurrency(t *testing.T) {
+	trie := NewConcurrentTree[int](WithMaxEntries(100), WithEvictionPolicy(NewLFUPolicy))
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprint(i % 300)
+				trie.Insert(key, i)
+				trie.Find(key)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if trie.Len() != 100 {
+		t.Errorf("expected len=100, got len=%d", trie.Len())
+	}
+}
+
+func TestCapacityFindDuringReadFrom(t *testing.T) {
+	trie := NewConcurrentTree[int](WithMaxEntries(10), WithCodec[int](intCodec{}))
+	for i := 0; i < 10; i++ {
+		trie.Insert(fmt.Sprint(i), i)
+	}
+	data, err := trie.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// restoring replaces the eviction policy, which concurrent finds must not see half way
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			trie.UnmarshalBinary(data)
+		}
+	}()
+	for i := 0; i < 2000; i++ {
+		trie.Find(fmt.Sprint(i % 10))
+	}
+	wg.Wait()
+	if trie.Len() != 10 {
+		t.Errorf("expected len=10, got len=%d", trie.Len())
+	}
+}
+
+func TestWithMaxBytesTypeMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for a size function of the wrong type")
+		}
+	}()
+	NewTree[int](WithMaxBytes(10, func(string, string) int64 { return 1 }))
+}
+
+func BenchmarkInsertWithMaxEntries(b *testing.B) {
+	for _, policy := range []struct {
+		name      string
+		newPolicy func() EvictionPolicy
+	}{{"LRU", NewLRUPolicy}, {"LFU", NewLFUPolicy}} {
+		b.Run(policy.name, func(b *testing.B) {
+			trie := NewTree[int](WithMaxEntries(1000), WithEvictionPolicy(policy.newPolicy))
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				trie.Insert(fmt.Sprint(n%5000), n)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package trie
+
+const (
+	// maxSparseChildren is the number of children a node keeps in its sorted arrays before switching to a 256-way array.
+	maxSparseChildren = 48
+	// minDenseChildren is the number of children below which a node switches back from the 256-way array to sorted arrays.
+	// It is lower than maxSparseChildren so that a node hovering around the limit does not switch back and forth.
+	minDenseChildren = 32
+)
+
+// children holds the children of a node, keyed by the first byte of their prefix. Like the node types of an adaptive radix
+// tree, it picks its layout by fan-out: up to maxSparseChildren children are kept in a pair of sorted arrays that grow through
+// capacities of 4, 16 and 48, and larger fan-outs use a 256-way array indexed directly by byte. Neither layout hashes.
+type children[T any] struct {
+	labels []byte         // sorted first bytes of the children, in the sparse layout
+	nodes  []*node[T]     // children in the order of labels, in the sparse layout
+	dense  *[256]*node[T] // children indexed by first byte, in the dense layout
+	count  int            // number of children, in the dense layout
+}
+
+// len returns the number of children.
+func (c *children[T]) len() int {
+	if c.dense != nil {
+		return c.count
+	}
+	return len(c.nodes)
+}
+
+// get returns the child filed under b, or nil if there is none.
+func (c *children[T]) get(b byte) *node[T] {
+	if c.dense != nil {
+		return c.dense[b]
+	}
+	for i, label := range c.labels {
+		if label == b {
+			return c.nodes[i]
+		}
+		if label > b {
+			break
+		}
+	}
+	return nil
+}
+
+// set files child under b, replacing the child filed there before.
+func (c *children[T]) set(b byte, child *node[T]) {
+	if c.dense != nil {
+		if c.dense[b] == nil {
+			c.count++
+		}
+		c.dense[b] = child
+		return
+	}
+	i := c.search(b)
+	if i < len(c.labels) && c.labels[i] == b {
+		c.nodes[i] = child
+		return
+	}
+	if len(c.labels) == maxSparseChildren {
+		c.toDense()
+		c.dense[b] = child
+		c.count++
+		return
+	}
+	if len(c.labels) == cap(c.labels) {
+		c.grow()
+	}
+	c.labels = append(c.labels, 0)
+	c.nodes = append(c.nodes, nil)
+	copy(c.labels[i+1:], c.labels[i:])
+	copy(c.nodes[i+1:], c.nodes[i:])
+	c.labels[i] = b
+	c.nodes[i] = child
+}
+
+// remove drops the child filed under b, if any.
+func (c *children[T]) remove(b byte) {
+	if c.dense != nil {
+		if c.dense[b] != nil {
+			c.dense[b] = nil
+			c.count--
+			if c.count < minDenseChildren {
+				c.toSparse()
+			}
+		}
+		return
+	}
+	i := c.search(b)
+	if i == len(c.labels) || c.labels[i] != b {
+		return
+	}
+	last := len(c.labels) - 1
+	copy(c.labels[i:], c.labels[i+1:])
+	copy(c.nodes[i:], c.nodes[i+1:])
+	c.nodes[last] = nil
+	c.labels = c.labels[:last]
+	c.nodes = c.nodes[:last]
+}
+
+// next returns the child with the smallest first byte that is not below from, or nil if there is none.
+func (c *children[T]) next(from int) *node[T] {
+	if c.dense != nil {
+		for b := from; b < len(c.dense); b++ {
+			if c.dense[b] != nil {
+				return c.dense[b]
+			}
+		}
+		return nil
+	}
+	if from > 0xff {
+		return nil
+	}
+	if i := c.search(byte(from)); i < len(c.nodes) {
+		return c.nodes[i]
+	}
+	return nil
+}
+
+// search returns the position of the first label in the sparse layout that is not below b.
+func (c *children[T]) search(b byte) int {
+	lo, hi := 0, len(c.labels)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if c.labels[mid] < b {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// grow moves the sparse layout to arrays of the next capacity.
+func (c *children[T]) grow() {
+	size := 4
+	switch {
+	case cap(c.labels) >= 16:
+		size = maxSparseChildren
+	case cap(c.labels) >= 4:
+		size = 16
+	}
+	labels := make([]byte, len(c.labels), size)
+	nodes := make([]*node[T], len(c.nodes), size)
+	copy(labels, c.labels)
+	copy(nodes, c.nodes)
+	c.labels, c.nodes = labels, nodes
+}
+
+// toDense moves the children from the sorted arrays to a 256-way array.
+func (c *children[T]) toDense() {
+	c.dense = new([256]*node[T])
+	for i, label := range c.labels {
+		c.dense[label] = c.nodes[i]
+	}
+	c.count = len(c.nodes)
+	c.labels, c.nodes = nil, nil
+}
+
+// toSparse moves the children from the 256-way array to sorted arrays.
+func (c *children[T]) toSparse() {
+	c.labels = make([]byte, 0, maxSparseChildren)
+	c.nodes = make([]*node[T], 0, maxSparseChildren)
+	for b, child := range c.dense {
+		if child != nil {
+			c.labels = append(c.labels, byte(b))
+			c.nodes = append(c.nodes, child)
+		}
+	}
+	c.dense, c.count = nil, 0
+}
+
+// clone returns a copy of c that can be modified without affecting c. The children themselves are shared.
+func (c *children[T]) clone() children[T] {
+	if c.dense != nil {
+		dense := *c.dense
+		return children[T]{dense: &dense, count: c.count}
+	}
+	if c.labels == nil {
+		return children[T]{}
+	}
+	labels := make([]byte, len(c.labels), cap(c.labels))
+	nodes := make([]*node[T], len(c.nodes), cap(c.nodes))
+	copy(labels, c.labels)
+	copy(nodes, c.nodes)
+	return children[T]{labels: labels, nodes: nodes}
+}
@@ -0,0 +1,128 @@
+package trie
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// leaf returns a node that can be filed under b.
+func leaf(b byte) *node[int] {
+	return newNode[int](0, []byte{b})
+}
+
+// labelsOf returns the first bytes of the children of c in iteration order.
+func labelsOf(c *children[int]) []byte {
+	var labels []byte
+	for child := c.next(0); child != nil; child = c.next(int(child.prefix[0]) + 1) {
+		labels = append(labels, child.prefix[0])
+	}
+	return labels
+}
+
+func TestChildrenLayouts(t *testing.T) {
+	var c children[int]
+	order := rand.New(rand.NewSource(1)).Perm(256)
+
+	for i, b := range order {
+		c.set(byte(b), leaf(byte(b)))
+		count := i + 1
+		if c.len() != count {
+			t.Fatalf("expected len=%d, got len=%d", count, c.len())
+		}
+		switch {
+		case count <= 4 && cap(c.labels) != 4,
+			count > 4 && count <= 16 && cap(c.labels) != 16,
+			count > 16 && count <= maxSparseChildren && cap(c.labels) != maxSparseChildren:
+			t.Fatalf("%d children: unexpected sparse capacity %d", count, cap(c.labels))
+		case count > maxSparseChildren && c.dense == nil:
+			t.Fatalf("%d children: expected the dense layout", count)
+		}
+	}
+
+	labels := labelsOf(&c)
+	for i, label := range labels {
+		if int(label) != i {
+			t.Fatalf("expected label %d at %d, got %d", i, i, label)
+		}
+	}
+
+	for i, b := range order {
+		c.remove(byte(b))
+		count := 255 - i
+		if c.len() != count {
+			t.Fatalf("expected len=%d, got len=%d", count, c.len())
+		}
+		if c.get(byte(b)) != nil {
+			t.Fatalf("expected %d to be removed", b)
+		}
+		if count >= minDenseChildren && c.dense == nil {
+			t.Fatalf("%d children: expected the dense layout to be kept", count)
+		}
+		if count < minDenseChildren && c.dense != nil {
+			t.Fatalf("%d children: expected the sparse layout", count)
+		}
+	}
+}
+
+func TestChildrenGetSetRemove(t *testing.T) {
+	var c children[int]
+	for _, b := range []byte("hello world") {
+		c.set(b, leaf(b))
+	}
+	if labels := string(labelsOf(&c)); labels != " dehlorw" {
+		t.Errorf("expected labels=%q, got labels=%q", " dehlorw", labels)
+	}
+
+	// Setting an existing label replaces the child
+	replacement := leaf('o')
+	c.set('o', replacement)
+	if c.get('o') != replacement || c.len() != 8 {
+		t.Errorf("expected 'o' to be replaced in place")
+	}
+
+	c.remove('h')
+	c.remove('x')
+	if labels := string(labelsOf(&c)); labels != " delorw" {
+		t.Errorf("expected labels=%q, got labels=%q", " delorw", labels)
+	}
+	if c.next('x') != nil || c.next(256) != nil {
+		t.Errorf("expected no child at or after 'x'")
+	}
+	if child := c.next('p'); child == nil || child.prefix[0] != 'r' {
+		t.Errorf("expected the next child after 'p' to be 'r'")
+	}
+}
+
+func TestSweepAcrossLayoutChange(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[int](WithClock(clock))
+
+	// The root fans out to 256 children, most of which expire together
+	for b := 0; b < 256; b++ {
+		key := string([]byte{byte(b)})
+		if b%16 == 0 {
+			trie.Insert(key, b)
+		} else {
+			trie.InsertWithExpiry(key, b, time.Second)
+		}
+	}
+	clock.Advance(time.Minute)
+
+	if removed := trie.Sweep(); removed != 240 {
+		t.Errorf("expected removed=240, got removed=%d", removed)
+	}
+	if trie.root.children.dense != nil {
+		t.Errorf("expected the root to switch back to the sparse layout")
+	}
+	keys := trie.KeysWithPrefix("", 0)
+	if len(keys) != 16 {
+		t.Fatalf("expected 16 keys, got %d", len(keys))
+	}
+	for i, key := range keys {
+		if key != string([]byte{byte(i * 16)}) {
+			t.Errorf("expected key %q, got %q", fmt.Sprint(i*16), key)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package trie
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time to a Tree. It is used to compute expiry times on insert and to check them on lookup and sweep.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the Clock backed by time.Now. It is used unless another Clock is configured.
+type systemClock struct{}
+
+// Now returns the current local time.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ManualClock is a Clock that only moves when told to. It is meant for tests of expiry behaviour that should not sleep.
+// A ManualClock is safe for concurrent use.
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManualClock creates and returns a new ManualClock set to start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now returns the time the clock is currently set to.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to now.
+func (c *ManualClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
@@ -0,0 +1,52 @@
+package trie
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+	if now := clock.Now(); !now.Equal(start) {
+		t.Errorf("expected now=%v, got now=%v", start, now)
+	}
+
+	clock.Advance(time.Hour)
+	if now := clock.Now(); !now.Equal(start.Add(time.Hour)) {
+		t.Errorf("expected now=%v, got now=%v", start.Add(time.Hour), now)
+	}
+
+	clock.Set(start)
+	if now := clock.Now(); !now.Equal(start) {
+		t.Errorf("expected now=%v, got now=%v", start, now)
+	}
+}
+
+func TestManualClockSharedByInsertAndFind(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewConcurrentTree[string](WithClock(clock))
+
+	// The expiry is computed from the clock at insert time
+	clock.Advance(time.Hour)
+	trie.InsertWithExpiry("key", "value", time.Minute)
+
+	clock.Advance(59 * time.Second)
+	if _, found := trie.Find("key"); !found {
+		t.Errorf("expected key to be found before its expiry")
+	}
+	if keys := trie.KeysWithPrefix("", 0); len(keys) != 1 {
+		t.Errorf("expected 1 key, got %d", len(keys))
+	}
+
+	clock.Advance(2 * time.Second)
+	if keys := trie.KeysWithPrefix("", 0); len(keys) != 0 {
+		t.Errorf("expected no keys, got %v", keys)
+	}
+	if removed := trie.Sweep(); removed != 1 {
+		t.Errorf("expected removed=1, got removed=%d", removed)
+	}
+	if _, found := trie.Find("key"); found {
+		t.Errorf("expected key to be expired")
+	}
+}
@@ -0,0 +1,52 @@
+package trie
+
+import (
+	"bytes"
+	"encoding/gob"
+)
+
+// Codec converts the values stored in a Tree to and from bytes when the Tree is serialized.
+type Codec[T any] interface {
+	Encode(value T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// GobCodec is a Codec that encodes values with encoding/gob. It is used unless another Codec is configured.
+type GobCodec[T any] struct{}
+
+// Encode returns the gob encoding of value.
+func (GobCodec[T]) Encode(value T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode decodes a value from its gob encoding.
+func (GobCodec[T]) Decode(data []byte) (T, error) {
+	var value T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// WithCodec makes the Tree serialize its values with codec instead of GobCodec. The value type of codec must match the value
+// type of the Tree, otherwise the constructor panics.
+func WithCodec[T any](codec Codec[T]) Option {
+	return func(o *options) {
+		o.set("WithCodec", scopeCodec)
+		o.codec = codec
+	}
+}
+
+// codecFor returns the Codec configured in o for a Tree of T, or GobCodec if there is none.
+func codecFor[T any](o *options) Codec[T] {
+	if o.codec == nil {
+		return GobCodec[T]{}
+	}
+	codec, ok := o.codec.(Codec[T])
+	if !ok {
+		panic("trie: WithCodec codec does not match the value type of the tree")
+	}
+	return codec
+}
@@ -0,0 +1,658 @@
+package trie
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// snapshotFile is the name of the snapshot of a DurableTree in its directory, in the format written by WriteTo.
+	snapshotFile = "trie.snapshot"
+	// logFile is the name of the write-ahead log of a DurableTree in its directory.
+	logFile = "trie.wal"
+
+	// logMagic and logVersion start every write-ahead log.
+	logMagic   = "GCWL"
+	logVersion = 1
+
+	logPut    = 1 // a record storing a value under a key
+	logDelete = 2 // a record removing a key
+
+	// logHeader is the length of the header of a log record: the length of its payload and the CRC-32C checksum of that length.
+	logHeader = 8
+)
+
+// ErrClosed is returned when writing to a DurableTree that has been closed.
+var ErrClosed = errors.New("trie: durable tree is closed")
+
+// SyncPolicy determines when a DurableTree flushes its log to stable storage with fsync.
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs the log before every write returns, so an acknowledged write survives a crash of the machine.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval syncs the log in the background at the interval set with WithSyncInterval, so a crash of the machine loses
+	// at most the writes of the last interval.
+	SyncInterval
+	// SyncNever leaves syncing to the operating system. Writes still reach the log before they return, so they survive a crash
+	// of the process, but not necessarily of the machine.
+	SyncNever
+)
+
+// DurableTree is a thread-safe Trie that survives crashes by recording every write in a write-ahead log in a directory before
+// applying it. On open, the Trie is restored from the last snapshot in the directory and the writes logged since then are
+// replayed. Once the log outgrows the compaction threshold, the Trie is written to a new snapshot and the log is emptied.
+//
+// Only Insert, InsertWithExpiry and Remove are logged. Expired values are not logged as they are reclaimed, since they are
+// expired again when they are replayed. Values evicted to keep the Trie within its capacity are logged as removals, since the
+// eviction policy depends on reads, which are not logged: replay applies the logged writes and removals without evicting,
+// and the capacity is enforced once it is done. OnEvict callbacks run once the write that triggered them has released the
+// DurableTree, so they may use it.
+type DurableTree[T any] struct {
+	tree      Tree[T]
+	mu        sync.Mutex // serializes the writes, so they are logged in the order they are applied
+	dir       string
+	file      *os.File
+	size      int64 // length of the log
+	policy    SyncPolicy
+	threshold int64
+	dirty     bool // the log has writes that have not been synced
+	closed    bool
+	stop      chan struct{}
+	done      chan struct{}
+
+	// unlogged are the keys evicted to keep the Tree within its capacity whose removal has not been logged yet, guarded by mu:
+	// such evictions only happen during writes, which hold it.
+	unlogged []string
+
+	onEvict   func(key string, value T, reason EvictReason)
+	evictMu   sync.Mutex
+	evictions []durableEviction[T] // values evicted from the Tree that have not been reported yet, guarded by evictMu
+}
+
+// durableEviction is a value evicted from the Tree of a DurableTree that still has to be reported to the OnEvict callback.
+type durableEviction[T any] struct {
+	key    string
+	value  T
+	reason EvictReason
+}
+
+// OpenDurableTree opens the DurableTree stored in dir, creating dir if it does not exist. The options apply to the underlying
+// Tree, whose values are encoded with its Codec both in the log and in snapshots, and configure the sync policy and the
+// compaction threshold. A log whose last record was torn by a crash is truncated after the last complete record, while a
+// corrupt record followed by others makes it fail with an error wrapping ErrInvalidFormat, leaving the log untouched.
+func OpenDurableTree[T any](dir string, opts ...Option) (*DurableTree[T], error) {
+	o := newOptions(opts)
+	o.check("OpenDurableTree", scopeClock|scopeTree|scopeCodec|scopeDurable)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	t := &DurableTree[T]{
+		tree:      newTree[T](o, true),
+		dir:       dir,
+		policy:    o.syncPolicy,
+		threshold: o.compactThreshold,
+	}
+	// the Tree reports evictions while mu may be held, so they are queued and reported once it is released
+	t.onEvict = t.tree.onEvict
+	t.tree.onEvict = t.queueEviction
+	defer t.reportEvictions()
+	// the snapshot and the log hold the Tree as it was kept within its capacity, which is only enforced once they are loaded
+	bound := t.tree.bound
+	t.tree.bound = nil
+	if err := t.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, logFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	t.file = file
+	if err := t.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := t.bind(bound); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if t.policy == SyncInterval {
+		t.stop = make(chan struct{})
+		t.done = make(chan struct{})
+		go t.syncEvery(o.syncInterval)
+	}
+	return t, nil
+}
+
+// WithSyncPolicy sets when a DurableTree syncs its log. The default is SyncAlways.
+func WithSyncPolicy(policy SyncPolicy) Option {
+	return func(o *options) {
+		o.set("WithSyncPolicy", scopeDurable)
+		o.syncPolicy = policy
+	}
+}
+
+// WithSyncInterval sets how often a DurableTree with the SyncInterval policy syncs its log. The default is one second. It
+// panics if interval is not positive.
+func WithSyncInterval(interval time.Duration) Option {
+	if interval <= 0 {
+		panic(fmt.Sprintf("trie: sync interval must be positive, got %v", interval))
+	}
+	return func(o *options) {
+		o.set("WithSyncInterval", scopeDurable)
+		o.syncInterval = interval
+	}
+}
+
+// WithCompactionThreshold makes a DurableTree compact its log into a snapshot once the log is longer than bytes. The default
+// is 64 MiB. A threshold of 0 disables automatic compaction, leaving it to Compact. It panics if bytes is negative.
+func WithCompactionThreshold(bytes int64) Option {
+	if bytes < 0 {
+		panic(fmt.Sprintf("trie: compaction threshold must not be negative, got %d", bytes))
+	}
+	return func(o *options) {
+		o.set("WithCompactionThreshold", scopeDurable)
+		o.compactThreshold = bytes
+	}
+}
+
+// Insert logs and adds a key-value pair to the Trie. It returns the old value (if any) and a boolean indicating if a value
+// was replaced. If the write cannot be logged, the Trie is left unchanged and the error is returned. If the write triggers a
+// compaction that fails, the write is kept and the error of the compaction is returned.
+func (t *DurableTree[T]) Insert(key string, value T) (oldValue T, replaced bool, err error) {
+	return t.insert(key, value, nil)
+}
+
+// InsertWithExpiry is like Insert, but the value expires after the given duration. The log records the expiry time, so a
+// value replayed after a restart expires at the same time.
+func (t *DurableTree[T]) InsertWithExpiry(key string, value T, expiry time.Duration) (oldValue T, replaced bool, err error) {
+	deadline := t.tree.now().Add(expiry)
+	return t.insert(key, value, &deadline)
+}
+
+// Remove logs and deletes the key-value pair from the Trie. It returns the old value (if any) and a boolean indicating if a
+// value was removed. Removing a key that is not stored writes nothing to the log. Errors are handled as by Insert.
+func (t *DurableTree[T]) Remove(key string) (oldValue T, removed bool, err error) {
+	defer t.reportEvictions()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return oldValue, false, ErrClosed
+	}
+	if entry, _ := t.tree.lookup(key); entry == nil {
+		return oldValue, false, nil
+	}
+	if err := t.append(deleteRecord(key)); err != nil {
+		return oldValue, false, err
+	}
+	oldValue, removed = t.tree.Remove(key)
+	return oldValue, removed, t.compactIfNeeded()
+}
+
+// Find retrieves the value associated with the given key. It returns false if the key does not exist or the value has expired.
+func (t *DurableTree[T]) Find(key string) (value T, found bool) {
+	defer t.reportEvictions()
+	return t.tree.Find(key)
+}
+
+// FindPrefix returns the non-expired entries whose keys start with prefix, in lexicographic byte order of the keys. If limit
+// is greater than zero, at most limit entries are returned.
+func (t *DurableTree[T]) FindPrefix(prefix string, limit int) []Entry[T] {
+	return t.tree.FindPrefix(prefix, limit)
+}
+
+// Walk calls fn for every non-expired entry in the Trie, in lexicographic byte order of the keys. Walking stops as soon as fn
+// returns false. fn is called while the Tree is locked for reading, so it must not modify the Trie.
+func (t *DurableTree[T]) Walk(fn func(key string, value T) bool) {
+	t.tree.Walk(fn)
+}
+
+// Len returns the number of values stored in the Trie. Values that have expired count until they are reclaimed by Find.
+func (t *DurableTree[T]) Len() int {
+	return t.tree.Len()
+}
+
+// Compact writes the Trie to a new snapshot and empties the log. The snapshot is written to a temporary file and renamed over
+// the previous one, so a crash leaves either the old or the new snapshot, and replaying the old log over the new snapshot
+// yields the same Trie. Writes wait while the Trie is compacted.
+func (t *DurableTree[T]) Compact() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrClosed
+	}
+	return t.compact()
+}
+
+// Sync flushes the log to stable storage, whatever the sync policy.
+func (t *DurableTree[T]) Sync() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrClosed
+	}
+	return t.sync()
+}
+
+// Close syncs and closes the log. The Trie can no longer be written to, but can still be read.
+func (t *DurableTree[T]) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return ErrClosed
+	}
+	t.closed = true
+	t.mu.Unlock()
+	if t.stop != nil {
+		close(t.stop)
+		<-t.done
+	}
+	err := t.file.Sync()
+	if closeErr := t.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// insert implements Insert and InsertWithExpiry.
+func (t *DurableTree[T]) insert(key string, value T, expiry *time.Time) (oldValue T, replaced bool, err error) {
+	data, err := t.tree.codec.Encode(value)
+	if err != nil {
+		return oldValue, false, fmt.Errorf("trie: encoding value of %q: %w", key, err)
+	}
+	e := &logEncoder{}
+	e.byte(logPut)
+	e.uvarint(uint64(len(key)))
+	e.write([]byte(key))
+	if expiry != nil {
+		e.byte(entryHasExpiry)
+		e.varint(expiry.UnixNano())
+	} else {
+		e.byte(0)
+	}
+	e.uvarint(uint64(len(data)))
+	e.write(data)
+
+	defer t.reportEvictions()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return oldValue, false, ErrClosed
+	}
+	if err := t.append(e); err != nil {
+		return oldValue, false, err
+	}
+	oldValue, replaced = t.tree.insert(key, value, expiry)
+	if err := t.logEvictions(); err != nil {
+		return oldValue, replaced, err
+	}
+	return oldValue, replaced, t.compactIfNeeded()
+}
+
+// bind makes the Tree enforce bound, which may be nil, after it has been loaded, and logs the removal of the values it evicts
+// to get within it, such as when the DurableTree is reopened with a smaller capacity.
+func (t *DurableTree[T]) bind(bound *capacity[T]) error {
+	if bound == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tree.lock.Lock()
+	t.tree.bound = bound
+	t.tree.reset()
+	evictions := t.tree.shrink()
+	t.tree.lock.Unlock()
+	t.tree.evictedAll(evictions, t.tree.now())
+	return t.logEvictions()
+}
+
+// logEvictions logs the removal of the values evicted to keep the Tree within its capacity since it was last called, so they
+// stay evicted after a restart. If that fails, they may be restored by the next replay, and evicted again once it is done.
+// It must be called while holding mu.
+func (t *DurableTree[T]) logEvictions() error {
+	keys := t.unlogged
+	t.unlogged = nil
+	for _, key := range keys {
+		if err := t.append(deleteRecord(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queueEviction queues a value evicted from the Tree to be reported to the OnEvict callback by reportEvictions, and records
+// the keys evicted to keep the Tree within its capacity so their removal can be logged.
+func (t *DurableTree[T]) queueEviction(key string, value T, reason EvictReason) {
+	if reason == EvictCapacity {
+		t.unlogged = append(t.unlogged, key)
+	}
+	if t.onEvict == nil {
+		return
+	}
+	t.evictMu.Lock()
+	t.evictions = append(t.evictions, durableEviction[T]{key: key, value: value, reason: reason})
+	t.evictMu.Unlock()
+}
+
+// reportEvictions reports the queued evictions to the OnEvict callback. It must be called without holding mu.
+func (t *DurableTree[T]) reportEvictions() {
+	if t.onEvict == nil {
+		return
+	}
+	t.evictMu.Lock()
+	evictions := t.evictions
+	t.evictions = nil
+	t.evictMu.Unlock()
+	for _, e := range evictions {
+		t.onEvict(e.key, e.value, e.reason)
+	}
+}
+
+// append writes the record encoded by e at the end of the log and syncs it if the sync policy requires. If either fails, the
+// record is cut off the log again. A record is a header holding the length of its payload and the CRC-32C checksum of that
+// length, then its payload and the CRC-32C checksum of its payload. It must be called while holding mu.
+func (t *DurableTree[T]) append(e *logEncoder) error {
+	payload := e.buf.Bytes()
+	frame := make([]byte, logHeader+len(payload)+4)
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:], crc32.Checksum(frame[:4], crcTable))
+	copy(frame[logHeader:], payload)
+	binary.BigEndian.PutUint32(frame[logHeader+len(payload):], crc32.Checksum(payload, crcTable))
+
+	if _, err := t.file.WriteAt(frame, t.size); err != nil {
+		// cut off whatever part of the record was written, so the next record follows the last complete one
+		t.file.Truncate(t.size)
+		return err
+	}
+	t.dirty = true
+	if t.policy == SyncAlways {
+		if err := t.sync(); err != nil {
+			// the write is reported as failed, so it must not be replayed after a restart either
+			t.file.Truncate(t.size)
+			return err
+		}
+	}
+	t.size += int64(len(frame))
+	return nil
+}
+
+// sync flushes the log to stable storage if it has unsynced writes. It must be called while holding mu.
+func (t *DurableTree[T]) sync() error {
+	if !t.dirty {
+		return nil
+	}
+	if err := t.file.Sync(); err != nil {
+		return err
+	}
+	t.dirty = false
+	return nil
+}
+
+// syncEvery syncs the log every interval until the DurableTree is closed.
+func (t *DurableTree[T]) syncEvery(interval time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+			t.mu.Lock()
+			if !t.closed {
+				// a failed sync leaves the log dirty, so it is retried on the next tick
+				t.sync()
+			}
+			t.mu.Unlock()
+		}
+	}
+}
+
+// compactIfNeeded compacts the log if it has outgrown the threshold. It must be called while holding mu.
+func (t *DurableTree[T]) compactIfNeeded() error {
+	if t.threshold == 0 || t.size <= t.threshold {
+		return nil
+	}
+	return t.compact()
+}
+
+// compact implements Compact. It must be called while holding mu.
+func (t *DurableTree[T]) compact() error {
+	tmp, err := os.CreateTemp(t.dir, snapshotFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = t.tree.WriteTo(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(t.dir, snapshotFile)); err != nil {
+		return err
+	}
+	if err := syncDir(t.dir); err != nil {
+		return err
+	}
+	// the snapshot now holds every logged write, so the log starts over
+	if err := t.file.Truncate(int64(len(logMagic) + 1)); err != nil {
+		return err
+	}
+	t.size = int64(len(logMagic) + 1)
+	t.dirty = true
+	return t.sync()
+}
+
+// loadSnapshot restores the Trie from the snapshot in its directory, if there is one.
+func (t *DurableTree[T]) loadSnapshot() error {
+	file, err := os.Open(filepath.Join(t.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := t.tree.ReadFrom(bufio.NewReader(file)); err != nil {
+		return fmt.Errorf("trie: reading snapshot: %w", err)
+	}
+	return nil
+}
+
+// replay applies the writes recorded in the log to the Trie, without reporting replaced values to the OnEvict callback,
+// truncates a torn record at its end and leaves the log ready to be appended to. An empty log gets its header written.
+func (t *DurableTree[T]) replay() error {
+	info, err := t.file.Stat()
+	if err != nil {
+		return err
+	}
+	header := int64(len(logMagic) + 1)
+	if info.Size() < header {
+		// the log is new, or a crash interrupted its creation
+		if _, err := t.file.WriteAt(append([]byte(logMagic), logVersion), 0); err != nil {
+			return err
+		}
+		t.size = header
+		t.dirty = true
+		return t.sync()
+	}
+
+	r := bufio.NewReader(t.file)
+	magic := make([]byte, header)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return err
+	}
+	if string(magic[:len(logMagic)]) != logMagic {
+		return fmt.Errorf("%w: not a write-ahead log", ErrInvalidFormat)
+	}
+	if version := magic[len(logMagic)]; version != logVersion {
+		return fmt.Errorf("%w: log version %d", ErrUnsupportedVersion, version)
+	}
+
+	onEvict := t.tree.onEvict
+	t.tree.onEvict = nil
+	defer func() {
+		t.tree.onEvict = onEvict
+	}()
+	t.size = header
+	now := t.tree.now()
+	for {
+		payload, n, err := readLogRecord(r, info.Size()-t.size)
+		if err == io.EOF {
+			return nil
+		}
+		if err == errTornRecord {
+			// the last record was torn by a crash while it was being written
+			if err := t.file.Truncate(t.size); err != nil {
+				return err
+			}
+			t.dirty = true
+			return t.sync()
+		}
+		if err != nil {
+			return fmt.Errorf("%w at offset %d of the log", err, t.size)
+		}
+		if err := t.apply(payload, now); err != nil {
+			return err
+		}
+		t.size += n
+	}
+}
+
+// errTornRecord is returned by readLogRecord for a record that was only partly written when the process crashed.
+var errTornRecord = errors.New("trie: torn log record")
+
+// readLogRecord reads the next record from r, where remaining bytes of the log are left, and returns its payload and its length
+// in the log. It returns io.EOF at the end of the log, and errTornRecord if the record is the last one in the log and was only
+// partly written: its header runs past the end of the log, or its header checks out and its payload runs past the end of the
+// log or ends exactly there but fails its checksum. Any other damage is corruption rather than the trace of a crash, and is
+// reported as ErrInvalidFormat.
+func readLogRecord(r io.Reader, remaining int64) (payload []byte, n int64, err error) {
+	if remaining == 0 {
+		return nil, 0, io.EOF
+	}
+	if remaining < logHeader {
+		return nil, 0, errTornRecord
+	}
+	var header [logHeader]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, 0, err
+	}
+	if binary.BigEndian.Uint32(header[4:]) != crc32.Checksum(header[:4], crcTable) {
+		return nil, 0, fmt.Errorf("%w: log record header checksum mismatch", ErrInvalidFormat)
+	}
+	n = logHeader + int64(binary.BigEndian.Uint32(header[:4])) + 4
+	if n > remaining {
+		return nil, 0, errTornRecord
+	}
+	record := make([]byte, n-logHeader)
+	if _, err := io.ReadFull(r, record); err != nil {
+		return nil, 0, err
+	}
+	payload, sum := record[:len(record)-4], record[len(record)-4:]
+	if binary.BigEndian.Uint32(sum) != crc32.Checksum(payload, crcTable) {
+		if n == remaining {
+			return nil, 0, errTornRecord
+		}
+		return nil, 0, fmt.Errorf("%w: log record checksum mismatch", ErrInvalidFormat)
+	}
+	return payload, n, nil
+}
+
+// apply applies a write recorded in the log. A value that has expired by now is removed instead of being stored.
+func (t *DurableTree[T]) apply(payload []byte, now time.Time) error {
+	d := newDecoder(bytes.NewReader(payload))
+	op := d.byte()
+	key := string(d.bytes(d.length()))
+	switch op {
+	case logDelete:
+		if d.err != nil {
+			return fmt.Errorf("%w: log record: %v", ErrInvalidFormat, d.err)
+		}
+		t.tree.Remove(key)
+	case logPut:
+		var expiry *time.Time
+		flags := d.byte()
+		if flags&^entryHasExpiry != 0 {
+			return fmt.Errorf("%w: log record flags %#x", ErrInvalidFormat, flags)
+		}
+		if flags&entryHasExpiry != 0 {
+			expiryTime := time.Unix(0, d.varint())
+			expiry = &expiryTime
+		}
+		data := d.bytes(d.length())
+		if d.err != nil {
+			return fmt.Errorf("%w: log record: %v", ErrInvalidFormat, d.err)
+		}
+		if expiry != nil && !now.Before(*expiry) {
+			t.tree.Remove(key)
+			return nil
+		}
+		value, err := t.tree.codec.Decode(data)
+		if err != nil {
+			return fmt.Errorf("trie: decoding value of %q: %w", key, err)
+		}
+		t.tree.insert(key, value, expiry)
+	default:
+		return fmt.Errorf("%w: log record type %d", ErrInvalidFormat, op)
+	}
+	return nil
+}
+
+// deleteRecord returns the payload of a log record removing key.
+func deleteRecord(key string) *logEncoder {
+	e := &logEncoder{}
+	e.byte(logDelete)
+	e.uvarint(uint64(len(key)))
+	e.write([]byte(key))
+	return e
+}
+
+// logEncoder encodes the payload of a log record with the primitives of the serialization format.
+type logEncoder struct {
+	buf     bytes.Buffer
+	scratch [binary.MaxVarintLen64]byte
+}
+
+func (e *logEncoder) write(p []byte) {
+	e.buf.Write(p)
+}
+
+func (e *logEncoder) byte(b byte) {
+	e.buf.WriteByte(b)
+}
+
+func (e *logEncoder) uvarint(v uint64) {
+	e.write(e.scratch[:binary.PutUvarint(e.scratch[:], v)])
+}
+
+func (e *logEncoder) varint(v int64) {
+	e.write(e.scratch[:binary.PutVarint(e.scratch[:], v)])
+}
+
+// syncDir flushes the entries of dir to stable storage, so a file renamed into it survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,350 @@
+package trie
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// durableEntries returns the entries of a DurableTree in key order.
+func durableEntries(t *DurableTree[int]) []Entry[int] {
+	var entries []Entry[int]
+	t.Walk(func(key string, value int) bool {
+		entries = append(entries, Entry[int]{Key: key, Value: value})
+		return true
+	})
+	return entries
+}
+
+// openDurable opens the DurableTree in dir, failing the test on error.
+func openDurable(t *testing.T, dir string, opts ...Option) *DurableTree[int] {
+	t.Helper()
+	tree, err := OpenDurableTree[int](dir, append([]Option{WithCodec[int](intCodec{})}, opts...)...)
+	if err != nil {
+		t.Fatalf("expected no error opening %s, got %v", dir, err)
+	}
+	return tree
+}
+
+func TestDurableTreeReplay(t *testing.T) {
+	dir := t.TempDir()
+	clock := NewManualClock(time.Unix(0, 0))
+	tree := openDurable(t, dir, WithClock(clock))
+	tree.Insert("a", 1)
+	tree.Insert("b", 2)
+	if old, replaced, err := tree.Insert("a", 10); err != nil || !replaced || old != 1 {
+		t.Errorf("expected to replace 1, got %d %v %v", old, replaced, err)
+	}
+	if old, removed, err := tree.Remove("b"); err != nil || !removed || old != 2 {
+		t.Errorf("expected to remove 2, got %d %v %v", old, removed, err)
+	}
+	if _, removed, err := tree.Remove("missing"); err != nil || removed {
+		t.Errorf("expected removing a missing key to do nothing, got %v %v", removed, err)
+	}
+	tree.InsertWithExpiry("short", 3, time.Minute)
+	tree.InsertWithExpiry("long", 4, time.Hour)
+	if err := tree.Close(); err != nil {
+		t.Fatalf("expected no error closing, got %v", err)
+	}
+	if _, _, err := tree.Insert("c", 5); err != ErrClosed {
+		t.Errorf("expected %v after closing, got %v", ErrClosed, err)
+	}
+
+	// values expire at the logged time, not relative to the replay
+	clock.Advance(10 * time.Minute)
+	var evicted []string
+	tree = openDurable(t, dir, WithClock(clock), WithOnEvict(func(key string, value int, reason EvictReason) {
+		evicted = append(evicted, key)
+	}))
+	defer tree.Close()
+	expected := []Entry[int]{{"a", 10}, {"long", 4}}
+	if entries := durableEntries(tree); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %v, got %v", expected, entries)
+	}
+	if tree.Len() != 2 {
+		t.Errorf("expected 2 values, got %d", tree.Len())
+	}
+	if len(evicted) != 0 {
+		t.Errorf("expected replay not to report evictions, got %v", evicted)
+	}
+	if remaining, found := tree.tree.TTL("long"); !found || remaining != 50*time.Minute {
+		t.Errorf("expected 50 minutes left, got %v %v", remaining, found)
+	}
+}
+
+func TestDurableTreeTornLog(t *testing.T) {
+	dir := t.TempDir()
+	tree := openDurable(t, dir)
+	tree.Insert("a", 1)
+	tree.Insert("b", 2)
+	tree.Close()
+
+	// a crash in the middle of the last record leaves part of it behind
+	path := filepath.Join(dir, logFile)
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-2); err != nil {
+		t.Fatal(err)
+	}
+	tree = openDurable(t, dir)
+	if entries := durableEntries(tree); !reflect.DeepEqual(entries, []Entry[int]{{"a", 1}}) {
+		t.Errorf("expected only a to survive, got %v", entries)
+	}
+
+	// the torn record is cut off, so new records follow the last complete one
+	tree.Insert("c", 3)
+	tree.Close()
+	tree = openDurable(t, dir)
+	defer tree.Close()
+	if entries := durableEntries(tree); !reflect.DeepEqual(entries, []Entry[int]{{"a", 1}, {"c", 3}}) {
+		t.Errorf("expected a and c, got %v", entries)
+	}
+}
+
+func TestDurableTreeCorruptLog(t *testing.T) {
+	dir := t.TempDir()
+	tree := openDurable(t, dir)
+	tree.Insert("a", 1)
+	tree.Insert("b", 2)
+	tree.Insert("c", 3)
+	tree.Close()
+
+	path := filepath.Join(dir, logFile)
+	log, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a byte flipped in the length or the payload of the first record, while later records follow, is corruption and not a
+	// torn end
+	for _, offset := range []int{len(logMagic) + 3, len(logMagic) + logHeader + 1} {
+		corrupt := append([]byte(nil), log...)
+		corrupt[offset] ^= 0xff
+		if err := os.WriteFile(path, corrupt, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OpenDurableTree[int](dir, WithCodec[int](intCodec{})); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("offset %d: expected %v, got %v", offset, ErrInvalidFormat, err)
+		}
+		if after, _ := os.ReadFile(path); !reflect.DeepEqual(after, corrupt) {
+			t.Errorf("offset %d: expected the corrupt log to be left untouched, got %d bytes instead of %d", offset, len(after), len(corrupt))
+		}
+	}
+
+	// a corrupt last record is the trace of a crash, and is cut off
+	log[len(log)-5] ^= 0xff
+	if err := os.WriteFile(path, log, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tree = openDurable(t, dir)
+	defer tree.Close()
+	if entries := durableEntries(tree); !reflect.DeepEqual(entries, []Entry[int]{{"a", 1}, {"b", 2}}) {
+		t.Errorf("expected a and b, got %v", entries)
+	}
+}
+
+func TestDurableTreeCompaction(t *testing.T) {
+	dir := t.TempDir()
+	tree := openDurable(t, dir, WithCompactionThreshold(200))
+	for i := 0; i < 100; i++ {
+		if _, _, err := tree.Insert("key", i); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	tree.Insert("other", -1)
+
+	// the log is compacted into the snapshot whenever it outgrows the threshold
+	if _, err := os.Stat(filepath.Join(dir, snapshotFile)); err != nil {
+		t.Errorf("expected a snapshot, got %v", err)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, logFile)); info.Size() > 200 {
+		t.Errorf("expected the log to stay within the threshold, got %d bytes", info.Size())
+	}
+	if err := tree.Compact(); err != nil {
+		t.Fatalf("expected no error compacting, got %v", err)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, logFile)); info.Size() != int64(len(logMagic)+1) {
+		t.Errorf("expected an empty log after compacting, got %d bytes", info.Size())
+	}
+	tree.Remove("other")
+	tree.Close()
+
+	tree = openDurable(t, dir)
+	defer tree.Close()
+	if entries := durableEntries(tree); !reflect.DeepEqual(entries, []Entry[int]{{"key", 99}}) {
+		t.Errorf("expected key=99, got %v", entries)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, snapshotFile+".*"))
+	if len(matches) != 0 {
+		t.Errorf("expected no temporary snapshots left, got %v", matches)
+	}
+}
+
+func TestDurableTreeCrashDuringCompaction(t *testing.T) {
+	dir := t.TempDir()
+	tree := openDurable(t, dir, WithCompactionThreshold(0))
+	tree.Insert("a", 1)
+	tree.Insert("b", 2)
+	tree.Remove("a")
+	tree.Sync()
+	log, err := os.ReadFile(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Compact()
+	tree.Close()
+
+	// a crash after the snapshot was written but before the log was emptied replays the old log over the new snapshot
+	if err := os.WriteFile(filepath.Join(dir, logFile), log, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tree = openDurable(t, dir)
+	defer tree.Close()
+	if entries := durableEntries(tree); !reflect.DeepEqual(entries, []Entry[int]{{"b", 2}}) {
+		t.Errorf("expected only b, got %v", entries)
+	}
+}
+
+// isDirty reports whether the log of tree has writes that have not been synced.
+func isDirty(tree *DurableTree[int]) bool {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+	return tree.dirty
+}
+
+func TestDurableTreeSyncPolicies(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncAlways, SyncInterval, SyncNever} {
+		dir := t.TempDir()
+		tree := openDurable(t, dir, WithSyncPolicy(policy), WithSyncInterval(time.Millisecond))
+		tree.Insert("a", 1)
+		switch policy {
+		case SyncAlways:
+			if isDirty(tree) {
+				t.Errorf("%d: expected the write to be synced before returning", policy)
+			}
+		case SyncInterval:
+			// the background syncer catches up without an explicit Sync
+			deadline := time.Now().Add(5 * time.Second)
+			for isDirty(tree) && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if isDirty(tree) {
+				t.Errorf("%d: expected the log to be synced in the background", policy)
+			}
+		case SyncNever:
+			if !isDirty(tree) {
+				t.Errorf("%d: expected the write to be left unsynced", policy)
+			}
+			if err := tree.Sync(); err != nil || isDirty(tree) {
+				t.Errorf("%d: expected Sync to sync the log, got %v", policy, err)
+			}
+		}
+		if err := tree.Close(); err != nil {
+			t.Errorf("%d: expected no error closing, got %v", policy, err)
+		}
+		if err := tree.Close(); err != ErrClosed {
+			t.Errorf("%d: expected %v closing twice, got %v", policy, ErrClosed, err)
+		}
+		tree = openDurable(t, dir)
+		if value, found := tree.Find("a"); !found || value != 1 {
+			t.Errorf("%d: expected a=1, got %d %v", policy, value, found)
+		}
+		tree.Close()
+	}
+}
+
+func TestDurableTreeOnEvictUsesTree(t *testing.T) {
+	dir := t.TempDir()
+	var tree *DurableTree[int]
+	var evicted []string
+	// the callback writes to the tree, which must not deadlock
+	tree = openDurable(t, dir, WithMaxEntries(2), WithOnEvict(func(key string, value int, reason EvictReason) {
+		evicted = append(evicted, key+":"+reason.String())
+		if reason == EvictCapacity {
+			tree.Remove("b")
+		}
+	}))
+	defer tree.Close()
+	tree.Insert("a", 1)
+	tree.Insert("a", 2)
+	tree.Insert("b", 3)
+	tree.Insert("c", 4)
+
+	expected := []string{"a:replaced", "a:capacity", "b:removed"}
+	if !reflect.DeepEqual(evicted, expected) {
+		t.Errorf("expected %v, got %v", expected, evicted)
+	}
+	if entries := durableEntries(tree); !reflect.DeepEqual(entries, []Entry[int]{{"c", 4}}) {
+		t.Errorf("expected only c, got %v", entries)
+	}
+}
+
+func TestDurableTreeCapacityReplay(t *testing.T) {
+	dir := t.TempDir()
+	tree := openDurable(t, dir, WithMaxEntries(2))
+	tree.Insert("a", 1)
+	tree.Insert("b", 2)
+	// finding a makes b the least recently used value, although reads are not logged
+	tree.Find("a")
+	tree.Insert("c", 3)
+	expected := []Entry[int]{{"a", 1}, {"c", 3}}
+	if entries := durableEntries(tree); !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("expected %v, got %v", expected, entries)
+	}
+	tree.Close()
+
+	// the eviction of b was logged, so the same values are restored
+	tree = openDurable(t, dir, WithMaxEntries(2))
+	if entries := durableEntries(tree); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %v after reopening, got %v", expected, entries)
+	}
+	tree.Close()
+
+	// reopening with a smaller capacity evicts, and logs the evictions too
+	var evicted []string
+	tree = openDurable(t, dir, WithMaxEntries(1), WithOnEvict(func(key string, value int, reason EvictReason) {
+		evicted = append(evicted, key+":"+reason.String())
+	}))
+	tree.Close()
+	if len(evicted) != 1 || evicted[0] != "a:capacity" {
+		t.Errorf("expected [a:capacity], got %v", evicted)
+	}
+	tree = openDurable(t, dir)
+	defer tree.Close()
+	if entries := durableEntries(tree); !reflect.DeepEqual(entries, []Entry[int]{{"c", 3}}) {
+		t.Errorf("expected only c, got %v", entries)
+	}
+}
+
+func TestDurableTreeInvalidLog(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, logFile), []byte("JUNKJUNK"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenDurableTree[int](dir); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("expected %v, got %v", ErrInvalidFormat, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, logFile), []byte(logMagic+"\x09"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenDurableTree[int](dir); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("expected %v, got %v", ErrUnsupportedVersion, err)
+	}
+}
+
+func BenchmarkDurableTreeInsert(b *testing.B) {
+	for _, policy := range []SyncPolicy{SyncAlways, SyncNever} {
+		b.Run(map[SyncPolicy]string{SyncAlways: "always", SyncNever: "never"}[policy], func(b *testing.B) {
+			tree, err := OpenDurableTree[int](b.TempDir(), WithCodec[int](intCodec{}), WithSyncPolicy(policy))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer tree.Close()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				tree.Insert("key", n)
+			}
+		})
+	}
+}
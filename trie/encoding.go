@@ -0,0 +1,361 @@
+package trie
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+const (
+	// encodingMagic starts every serialized Tree.
+	encodingMagic = "GCTR"
+	// encodingVersion is the version of the format written by WriteTo. Version 1 lacks sliding expiry durations and can still be read.
+	encodingVersion = 2
+
+	recordEnd   = 0 // ends the list of entries
+	recordEntry = 1 // starts an entry
+
+	entryHasExpiry  = 1 << 0 // the entry carries an absolute expiry time
+	entryHasSliding = 1 << 1 // the entry carries a sliding expiry duration, after its expiry time
+
+	// maxEncodedLength bounds the key and value lengths accepted by ReadFrom, so corrupt input cannot trigger huge allocations.
+	maxEncodedLength = 1 << 30
+)
+
+var (
+	// ErrInvalidFormat is returned when restoring a Tree from data that is not a serialized Tree.
+	ErrInvalidFormat = errors.New("trie: invalid serialized tree")
+	// ErrUnsupportedVersion is returned when restoring a Tree that was serialized with an unknown version of the format.
+	ErrUnsupportedVersion = errors.New("trie: unsupported serialization version")
+	// ErrChecksumMismatch is returned when restoring a Tree from data that has been corrupted.
+	ErrChecksumMismatch = errors.New("trie: checksum mismatch")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// WriteTo serializes the non-expired entries of the Trie to w, values encoded with the Tree's Codec and expiry times kept as
+// absolute times. The format starts with a magic string and a version, and ends with a CRC-32C checksum of everything before it.
+// The Tree is locked for reading while it is written. It returns the number of bytes written.
+func (t *Tree[T]) WriteTo(w io.Writer) (int64, error) {
+	if t.syncSafe {
+		t.lock.RLock()
+		defer t.lock.RUnlock()
+	}
+	cw := &countingWriter{w: w}
+	crc := crc32.New(crcTable)
+	e := &encoder{w: bufio.NewWriter(io.MultiWriter(cw, crc))}
+
+	e.write([]byte(encodingMagic))
+	e.byte(encodingVersion)
+	count := 0
+	t.root.walkEntries(make([]byte, 0, 64), t.now(), func(key []byte, entry *valueWithExpiry[T]) bool {
+		data, err := t.codec.Encode(entry.value)
+		if err != nil {
+			e.err = fmt.Errorf("trie: encoding value of %q: %w", key, err)
+			return false
+		}
+		e.byte(recordEntry)
+		e.uvarint(uint64(len(key)))
+		e.write(key)
+		var flags byte
+		if entry.expiry != nil {
+			flags |= entryHasExpiry
+		}
+		if entry.sliding > 0 {
+			flags |= entryHasSliding
+		}
+		e.byte(flags)
+		if entry.expiry != nil {
+			e.varint(entry.expiry.UnixNano())
+		}
+		if entry.sliding > 0 {
+			e.varint(int64(entry.sliding))
+		}
+		e.uvarint(uint64(len(data)))
+		e.write(data)
+		count++
+		return e.err == nil
+	})
+	e.byte(recordEnd)
+	e.uvarint(uint64(count))
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	if e.err != nil {
+		return cw.n, e.err
+	}
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	_, err := cw.Write(sum[:])
+	return cw.n, err
+}
+
+// ReadFrom replaces the contents of the Trie with the entries serialized by WriteTo in r, decoding values with the Tree's Codec.
+// Entries whose expiry time has already passed are skipped. Values are only decoded, and the contents only replaced, once the
+// whole input has been read and its checksum verified, and the replaced values are not reported to the OnEvict callback. If the
+// restored values exceed the capacity of the Tree, the surplus is evicted and reported as usual. ReadFrom reads no further than
+// the end of the serialized Tree, so it can be embedded in a larger stream; it reads r a byte at a time where it has to, so r
+// should be buffered unless it is an io.ByteReader. It returns the number of bytes read.
+func (t *Tree[T]) ReadFrom(r io.Reader) (int64, error) {
+	d := newDecoder(r)
+	if magic := d.bytes(len(encodingMagic)); d.err == nil && string(magic) != encodingMagic {
+		return d.n, ErrInvalidFormat
+	}
+	if version := d.byte(); d.err == nil && (version < 1 || version > encodingVersion) {
+		return d.n, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	type encoded struct {
+		key   string
+		entry *valueWithExpiry[T] // the value is decoded from data once the checksum has been verified
+		data  []byte
+	}
+	var entries []encoded
+	for d.err == nil {
+		switch record := d.byte(); {
+		case d.err != nil:
+		case record == recordEnd:
+			if count := d.uvarint(); d.err == nil && count != uint64(len(entries)) {
+				return d.n, ErrInvalidFormat
+			}
+			decode := func() error {
+				for _, entry := range entries {
+					value, err := t.codec.Decode(entry.data)
+					if err != nil {
+						return fmt.Errorf("trie: decoding value of %q: %w", entry.key, err)
+					}
+					entry.entry.value = value
+				}
+				return nil
+			}
+			return d.n, t.restore(d, decode, func(gen uint64, root *node[T], now time.Time) (nodes, values int) {
+				for _, entry := range entries {
+					if entry.entry.expired(now) {
+						continue
+					}
+					_, target, created := root.insert(gen, entry.key)
+					target.setEntry(entry.entry)
+					nodes += created
+					values++
+				}
+				return nodes, values
+			})
+		case record == recordEntry:
+			key := d.bytes(d.length())
+			entry := &valueWithExpiry[T]{}
+			flags := d.byte()
+			if flags&^(entryHasExpiry|entryHasSliding) != 0 {
+				return d.n, ErrInvalidFormat
+			}
+			if flags&entryHasExpiry != 0 {
+				at := time.Unix(0, d.varint())
+				entry.expiry = &at
+			}
+			if flags&entryHasSliding != 0 {
+				entry.sliding = time.Duration(d.varint())
+			}
+			data := d.bytes(d.length())
+			if d.err != nil {
+				break
+			}
+			entries = append(entries, encoded{key: string(key), entry: entry, data: data})
+		default:
+			return d.n, ErrInvalidFormat
+		}
+	}
+	return d.n, d.err
+}
+
+// restore verifies the checksum at the end of d and, if it matches, decodes the values with decode and replaces the contents of
+// the Trie with the nodes built by fill under a fresh root. Corrupt input thus never reaches the Codec.
+func (t *Tree[T]) restore(d *decoder, decode func() error, fill func(gen uint64, root *node[T], now time.Time) (nodes, values int)) error {
+	expected := d.crc.Sum32()
+	sum := d.bytes(4)
+	if d.err != nil {
+		return d.err
+	}
+	if binary.BigEndian.Uint32(sum) != expected {
+		return ErrChecksumMismatch
+	}
+	if err := decode(); err != nil {
+		return err
+	}
+	if t.syncSafe {
+		t.lock.Lock()
+	}
+	now := t.now()
+	root := newNode[T](t.gen, nil)
+	nodes, values := fill(t.gen, root, now)
+	t.root, t.nodes, t.values = root, nodes+1, values
+	t.reset()
+	evictions := t.shrink()
+	if t.syncSafe {
+		t.lock.Unlock()
+	}
+	t.evictedAll(evictions, now)
+	return nil
+}
+
+// MarshalBinary serializes the non-expired entries of the Trie in the format written by WriteTo.
+func (t *Tree[T]) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := t.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the contents of the Trie with the entries serialized in data, as ReadFrom does.
+func (t *Tree[T]) UnmarshalBinary(data []byte) error {
+	_, err := t.ReadFrom(bytes.NewReader(data))
+	return err
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// encoder writes the primitives of the format and remembers the first error.
+type encoder struct {
+	w       *bufio.Writer
+	err     error
+	scratch [binary.MaxVarintLen64]byte
+}
+
+func (e *encoder) write(p []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(p)
+	}
+}
+
+func (e *encoder) byte(b byte) {
+	if e.err == nil {
+		e.err = e.w.WriteByte(b)
+	}
+}
+
+func (e *encoder) uvarint(v uint64) {
+	e.write(e.scratch[:binary.PutUvarint(e.scratch[:], v)])
+}
+
+func (e *encoder) varint(v int64) {
+	e.write(e.scratch[:binary.PutVarint(e.scratch[:], v)])
+}
+
+// decoder reads the primitives of the format, feeds every byte it reads to a checksum and remembers the first error.
+// A premature end of input is reported as io.ErrUnexpectedEOF.
+type decoder struct {
+	r   io.Reader
+	br  io.ByteReader // reads single bytes from r
+	crc hash.Hash32
+	n   int64
+	err error
+}
+
+// newDecoder returns a decoder reading from r without reading ahead, so r is left right after the last primitive read.
+func newDecoder(r io.Reader) *decoder {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = &byteReader{r: r}
+	}
+	return &decoder{r: r, br: br, crc: crc32.New(crcTable)}
+}
+
+func (d *decoder) ReadByte() (byte, error) {
+	b, err := d.br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	d.n++
+	d.crc.Write([]byte{b})
+	return b, nil
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	b, err := d.ReadByte()
+	d.fail(err)
+	return b
+}
+
+// bytes reads the next n bytes. The buffer grows as the bytes arrive rather than being allocated upfront, so a corrupt length
+// fails on the premature end of input instead of allocating.
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if n < 64<<10 {
+		buf.Grow(n)
+	}
+	read, err := io.CopyN(&buf, d.r, int64(n))
+	d.n += read
+	d.crc.Write(buf.Bytes())
+	d.fail(err)
+	return buf.Bytes()
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d)
+	d.fail(err)
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(d)
+	d.fail(err)
+	return v
+}
+
+// length reads a key or value length and rejects lengths no valid input can have.
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if d.err == nil && n > maxEncodedLength {
+		d.err = ErrInvalidFormat
+	}
+	return int(n)
+}
+
+// byteReader reads single bytes from a reader that cannot do so itself, without buffering.
+type byteReader struct {
+	r   io.Reader
+	buf [1]byte
+}
+
+func (b *byteReader) ReadByte() (byte, error) {
+	if _, err := io.ReadFull(b.r, b.buf[:]); err != nil {
+		return 0, err
+	}
+	return b.buf[0], nil
+}
+
+func (d *decoder) fail(err error) {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if d.err == nil {
+		d.err = err
+	}
+}
@@ -0,0 +1,300 @@
+package trie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// intCodec encodes ints as decimal strings.
+type intCodec struct{}
+
+func (intCodec) Encode(value int) ([]byte, error) {
+	return []byte(strconv.Itoa(value)), nil
+}
+
+func (intCodec) Decode(data []byte) (int, error) {
+	return strconv.Atoi(string(data))
+}
+
+// entriesOf returns every non-expired entry of trie in key order.
+func entriesOf[T any](trie *Tree[T]) []Entry[T] {
+	var entries []Entry[T]
+	trie.Walk(func(key string, value T) bool {
+		entries = append(entries, Entry[T]{Key: key, Value: value})
+		return true
+	})
+	return entries
+}
+
+func TestWriteToReadFrom(t *testing.T) {
+	source := NewTree[int](WithCodec[int](intCodec{}))
+	for i := 0; i < 1000; i++ {
+		source.Insert(fmt.Sprintf("key-%d", i), i)
+	}
+	source.Insert("", -1)
+
+	var buf bytes.Buffer
+	written, err := source.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if written != int64(buf.Len()) {
+		t.Errorf("expected written=%d, got written=%d", buf.Len(), written)
+	}
+
+	restored := NewTree[int](WithCodec[int](intCodec{}))
+	restored.Insert("stale", 0)
+	read, err := restored.ReadFrom(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if read != written {
+		t.Errorf("expected read=%d, got read=%d", written, read)
+	}
+
+	// The restored tree replaces the previous contents
+	if _, found := restored.Find("stale"); found {
+		t.Errorf("expected stale to be replaced")
+	}
+	if !reflect.DeepEqual(entriesOf(&restored), entriesOf(&source)) {
+		t.Errorf("restored entries differ from the source entries")
+	}
+	if restored.Stats() != source.Stats() {
+		t.Errorf("expected stats=%+v, got stats=%+v", source.Stats(), restored.Stats())
+	}
+	checkCompressed(t, restored.root, true)
+}
+
+func TestMarshalBinaryGob(t *testing.T) {
+	type user struct {
+		Name  string
+		Roles []string
+	}
+	source := NewConcurrentTree[user]()
+	source.Insert("alice", user{Name: "Alice", Roles: []string{"admin"}})
+	source.Insert("bob", user{Name: "Bob"})
+
+	data, err := source.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restored := NewConcurrentTree[user]()
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(entriesOf(&restored), entriesOf(&source)) {
+		t.Errorf("expected entries=%v, got entries=%v", entriesOf(&source), entriesOf(&restored))
+	}
+}
+
+func TestSerializationPreservesExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	source := NewTree[string](WithClock(clock))
+	source.Insert("permanent", "a")
+	source.InsertWithExpiry("short", "b", time.Minute)
+	source.InsertWithExpiry("long", "c", time.Hour)
+	source.InsertWithExpiry("expired", "d", -time.Second)
+
+	data, err := source.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The restoring process starts later: the absolute expiry times still apply
+	clock.Advance(30 * time.Minute)
+	restored := NewTree[string](WithClock(clock))
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Entry[string]{{"long", "c"}, {"permanent", "a"}}
+	if entries := entriesOf(&restored); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected entries=%v, got entries=%v", expected, entries)
+	}
+	if restored.Len() != 2 {
+		t.Errorf("expected len=2, got len=%d", restored.Len())
+	}
+
+	clock.Advance(time.Hour)
+	if _, found := restored.Find("long"); found {
+		t.Errorf("expected long to expire at its original time")
+	}
+}
+
+func TestSerializationPreservesSlidingExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	source := NewTree[string](WithClock(clock))
+	source.InsertWithSlidingExpiry("session", "a", time.Minute)
+
+	data, err := source.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restored := NewTree[string](WithClock(clock))
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The restored entry keeps sliding
+	for i := 0; i < 3; i++ {
+		clock.Advance(45 * time.Second)
+		if _, found := restored.Find("session"); !found {
+			t.Fatalf("expected session to slide, expired after %d lookups", i)
+		}
+	}
+	if remaining, _ := restored.TTL("session"); remaining != time.Minute {
+		t.Errorf("expected remaining=1m, got remaining=%v", remaining)
+	}
+}
+
+func TestReadFromVersion1(t *testing.T) {
+	source := NewTree[string]()
+	source.Insert("a", "1")
+	data, err := source.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Version 1 has the same layout for entries without a sliding expiry
+	data[len(encodingMagic)] = 1
+	binary.BigEndian.PutUint32(data[len(data)-4:], crc32.Checksum(data[:len(data)-4], crcTable))
+	restored := NewTree[string]()
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value, found := restored.Find("a"); !found || value != "1" {
+		t.Errorf("expected found=true, value='1', got found=%v, value=%v", found, value)
+	}
+}
+
+func TestReadFromRejectsInvalidInput(t *testing.T) {
+	source := NewTree[int](WithCodec[int](intCodec{}))
+	source.Insert("one", 1)
+	source.Insert("two", 2)
+	data, err := source.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[len(corrupt)/2] ^= 0xff
+	badVersion := append([]byte(nil), data...)
+	badVersion[len(encodingMagic)] = 99
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"empty", nil, io.ErrUnexpectedEOF},
+		{"magic", []byte("JUNKJUNK"), ErrInvalidFormat},
+		{"version", badVersion, ErrUnsupportedVersion},
+		{"truncated", data[:len(data)-2], io.ErrUnexpectedEOF},
+		{"corrupt", corrupt, nil},
+	}
+	for _, test := range tests {
+		restored := NewTree[int](WithCodec[int](intCodec{}))
+		restored.Insert("kept", 0)
+		err := restored.UnmarshalBinary(test.data)
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if test.expected != nil && !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.expected, err)
+		}
+
+		// A failed restore leaves the tree untouched
+		if value, found := restored.Find("kept"); !found || value != 0 || restored.Len() != 1 {
+			t.Errorf("%s: expected the tree to be left untouched", test.name)
+		}
+	}
+
+	// Flipping a byte of the trailer itself is caught by the checksum
+	flipped := append([]byte(nil), data...)
+	flipped[len(flipped)-1] ^= 0xff
+	restored := NewTree[int](WithCodec[int](intCodec{}))
+	if err := restored.UnmarshalBinary(flipped); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected error %v, got %v", ErrChecksumMismatch, err)
+	}
+
+	// A corrupt value is caught by the checksum before it reaches the codec: the key is followed by the flags and the length
+	// of the value
+	flipped = append([]byte(nil), data...)
+	flipped[bytes.Index(flipped, []byte("one"))+len("one")+2] = 'x'
+	if err := restored.UnmarshalBinary(flipped); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected error %v, got %v", ErrChecksumMismatch, err)
+	}
+}
+
+func TestReadFromStopsAtEnd(t *testing.T) {
+	source := NewTree[int](WithCodec[int](intCodec{}))
+	source.Insert("one", 1)
+	data, err := source.MarshalBinary()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the bytes after the serialized tree are left in the reader, whether it can read single bytes or not
+	readers := map[string]func(*bytes.Buffer) io.Reader{
+		"byte reader": func(b *bytes.Buffer) io.Reader { return b },
+		"plain reader": func(b *bytes.Buffer) io.Reader {
+			return struct{ io.Reader }{b}
+		},
+	}
+	for name, reader := range readers {
+		buf := bytes.NewBuffer(append(append([]byte(nil), data...), "TRAILER"...))
+		restored := NewTree[int](WithCodec[int](intCodec{}))
+		n, err := restored.ReadFrom(reader(buf))
+		if err != nil || n != int64(len(data)) {
+			t.Errorf("%s: expected %d bytes read, got %d %v", name, len(data), n, err)
+		}
+		if rest := buf.String(); rest != "TRAILER" {
+			t.Errorf("%s: expected the trailer to be left, got %q", name, rest)
+		}
+		if value, found := restored.Find("one"); !found || value != 1 {
+			t.Errorf("%s: expected one=1, got %d %v", name, value, found)
+		}
+	}
+}
+
+func TestWithCodecTypeMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for a codec of the wrong value type")
+		}
+	}()
+	NewTree[string](WithCodec[int](intCodec{}))
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+	trie := NewTree[int](WithCodec[int](intCodec{}))
+	for n := 0; n < 10000; n++ {
+		trie.Insert(fmt.Sprintf("key-%d", n), n)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		trie.WriteTo(io.Discard)
+	}
+}
+
+func BenchmarkReadFrom(b *testing.B) {
+	source := NewTree[int](WithCodec[int](intCodec{}))
+	for n := 0; n < 10000; n++ {
+		source.Insert(fmt.Sprintf("key-%d", n), n)
+	}
+	data, _ := source.MarshalBinary()
+	trie := NewTree[int](WithCodec[int](intCodec{}))
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		trie.UnmarshalBinary(data)
+	}
+}
@@ -0,0 +1,52 @@
+package trie
+
+// EvictReason describes why a value left the Tree.
+type EvictReason int
+
+const (
+	// EvictRemoved means the value was deleted with Remove.
+	EvictRemoved EvictReason = iota
+	// EvictReplaced means the value was overwritten by an insert under the same key.
+	EvictReplaced
+	// EvictExpired means the value had expired when it left the Tree, either lazily on Find, during a sweep, or when it was removed or overwritten.
+	EvictExpired
+	// EvictCapacity means the value was evicted by the eviction policy to keep the Tree within its capacity.
+	EvictCapacity
+)
+
+// String returns the name of the reason.
+func (r EvictReason) String() string {
+	switch r {
+	case EvictRemoved:
+		return "removed"
+	case EvictReplaced:
+		return "replaced"
+	case EvictExpired:
+		return "expired"
+	case EvictCapacity:
+		return "capacity"
+	default:
+		return "unknown"
+	}
+}
+
+// WithOnEvict registers fn to be called whenever a value leaves the Tree. fn is called after the Tree's lock has been released,
+// so it may safely use the Tree. The value type of fn must match the value type of the Tree, otherwise the constructor panics.
+func WithOnEvict[T any](fn func(key string, value T, reason EvictReason)) Option {
+	return func(o *options) {
+		o.set("WithOnEvict", scopeTree)
+		o.onEvict = fn
+	}
+}
+
+// onEvictFor returns the OnEvict callback configured in o for a Tree of T, or nil if there is none.
+func onEvictFor[T any](o *options) func(key string, value T, reason EvictReason) {
+	if o.onEvict == nil {
+		return nil
+	}
+	fn, ok := o.onEvict.(func(key string, value T, reason EvictReason))
+	if !ok {
+		panic("trie: WithOnEvict callback does not match the value type of the tree")
+	}
+	return fn
+}
@@ -0,0 +1,135 @@
+package trie
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// evictionLog records the evictions reported by a Tree.
+type evictionLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *evictionLog) record(key string, value string, reason EvictReason) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, key+"="+value+":"+reason.String())
+}
+
+func (l *evictionLog) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := l.events
+	l.events = nil
+	return events
+}
+
+func TestOnEvictRemoveAndReplace(t *testing.T) {
+	log := &evictionLog{}
+	trie := NewTree[string](WithOnEvict(log.record))
+
+	trie.Insert("key", "first")
+	if events := log.take(); len(events) != 0 {
+		t.Errorf("expected no evictions on first insert, got %v", events)
+	}
+
+	trie.Insert("key", "second")
+	if events := log.take(); !reflect.DeepEqual(events, []string{"key=first:replaced"}) {
+		t.Errorf("expected [key=first:replaced], got %v", events)
+	}
+
+	trie.Remove("key")
+	if events := log.take(); !reflect.DeepEqual(events, []string{"key=second:removed"}) {
+		t.Errorf("expected [key=second:removed], got %v", events)
+	}
+
+	// Removing a missing key reports nothing
+	trie.Remove("key")
+	if events := log.take(); len(events) != 0 {
+		t.Errorf("expected no evictions, got %v", events)
+	}
+}
+
+func TestOnEvictExpired(t *testing.T) {
+	log := &evictionLog{}
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock), WithOnEvict(log.record))
+
+	trie.InsertWithExpiry("found", "a", time.Second)
+	trie.InsertWithExpiry("swept", "b", time.Second)
+	trie.InsertWithExpiry("replaced", "c", time.Second)
+	trie.InsertWithExpiry("removed", "d", time.Second)
+	clock.Advance(time.Minute)
+
+	// Find removes the expired value lazily
+	if _, found := trie.Find("found"); found {
+		t.Errorf("expected found=false")
+	}
+	if events := log.take(); !reflect.DeepEqual(events, []string{"found=a:expired"}) {
+		t.Errorf("expected [found=a:expired], got %v", events)
+	}
+	if _, found := trie.Find("found"); found {
+		t.Errorf("expected found=false")
+	}
+	if events := log.take(); len(events) != 0 {
+		t.Errorf("expected a single expiry report, got %v", events)
+	}
+
+	// Overwriting or removing an expired value reports it as expired
+	trie.Insert("replaced", "e")
+	trie.Remove("removed")
+	if events := log.take(); !reflect.DeepEqual(events, []string{"replaced=c:expired", "removed=d:expired"}) {
+		t.Errorf("expected [replaced=c:expired removed=d:expired], got %v", events)
+	}
+
+	if removed := trie.Sweep(); removed != 1 {
+		t.Errorf("expected removed=1, got removed=%d", removed)
+	}
+	if events := log.take(); !reflect.DeepEqual(events, []string{"swept=b:expired"}) {
+		t.Errorf("expected [swept=b:expired], got %v", events)
+	}
+}
+
+func TestOnEvictCallbackCanUseTree(t *testing.T) {
+	var trie Tree[string]
+	trie = NewConcurrentTree[string](WithOnEvict(func(key string, value string, reason EvictReason) {
+		// Re-entering the tree would deadlock if the lock were still held
+		if reason == EvictRemoved {
+			trie.Insert("archive/"+key, value)
+		}
+	}))
+
+	trie.Insert("key", "value")
+	trie.Remove("key")
+
+	value, found := trie.Find("archive/key")
+	if !found || value != "value" {
+		t.Errorf("expected found=true, value='value', got found=%v, value=%v", found, value)
+	}
+}
+
+func TestOnEvictTypeMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a panic for a callback of the wrong value type")
+		}
+	}()
+	NewTree[int](WithOnEvict(func(string, string, EvictReason) {}))
+}
+
+func TestEvictReasonString(t *testing.T) {
+	reasons := map[EvictReason]string{
+		EvictRemoved:    "removed",
+		EvictReplaced:   "replaced",
+		EvictExpired:    "expired",
+		EvictReason(42): "unknown",
+	}
+	for reason, expected := range reasons {
+		if reason.String() != expected {
+			t.Errorf("expected %q, got %q", expected, reason.String())
+		}
+	}
+}
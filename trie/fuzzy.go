@@ -0,0 +1,119 @@
+package trie
+
+import (
+	"sort"
+	"time"
+)
+
+// FuzzyMatch is an entry found by a fuzzy search, together with the edit distance between its key and the query.
+type FuzzyMatch[T any] struct {
+	Key      string
+	Value    T
+	Distance int
+}
+
+// FuzzyFind returns the non-expired entries whose keys are within Levenshtein distance maxDistance of query, counting
+// insertions, deletions and substitutions of single bytes. The matches are sorted by distance, and then in lexicographic byte
+// order of the keys. The distances are computed row by row while descending the Trie, so every key sharing a prefix shares
+// the work, and branches whose prefix is already too far from query are skipped.
+func (t *Tree[T]) FuzzyFind(query string, maxDistance int) []FuzzyMatch[T] {
+	return t.fuzzyFind(query, maxDistance, false)
+}
+
+// FuzzyFindDamerau is like FuzzyFind, but also counts the transposition of two adjacent bytes as a single edit (optimal string
+// alignment distance).
+func (t *Tree[T]) FuzzyFindDamerau(query string, maxDistance int) []FuzzyMatch[T] {
+	return t.fuzzyFind(query, maxDistance, true)
+}
+
+// fuzzyFind implements FuzzyFind and, if transpositions is set, FuzzyFindDamerau.
+func (t *Tree[T]) fuzzyFind(query string, maxDistance int, transpositions bool) []FuzzyMatch[T] {
+	if maxDistance < 0 {
+		return nil
+	}
+	s := &fuzzySearch{query: query, max: maxDistance, transpositions: transpositions}
+	first := make([]int, len(query)+1)
+	for i := range first {
+		first[i] = i
+	}
+	s.rows = append(s.rows, first)
+
+	var matches []FuzzyMatch[T]
+	if t.syncSafe {
+		t.lock.RLock()
+	}
+	t.root.fuzzy(s, make([]byte, 0, 64), t.now(), func(key []byte, value T, distance int) {
+		matches = append(matches, FuzzyMatch[T]{Key: string(key), Value: value, Distance: distance})
+	})
+	if t.syncSafe {
+		t.lock.RUnlock()
+	}
+	// the walk finds the keys in order, so a stable sort keeps them in order within each distance
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Distance < matches[j].Distance
+	})
+	return matches
+}
+
+// fuzzySearch holds the state of a fuzzy search while it descends the Trie.
+type fuzzySearch struct {
+	query          string
+	max            int
+	transpositions bool
+	rows           [][]int // rows[d][i] is the edit distance between the first d bytes of the path and the first i bytes of the query
+}
+
+// fuzzy calls fn for every value that is not expired at now in the subtree rooted at n whose key is within the maximum
+// distance of the query. key is the path leading to n, whose rows have been computed.
+func (n *node[T]) fuzzy(s *fuzzySearch, key []byte, now time.Time, fn func(key []byte, value T, distance int)) {
+	if value, ok := n.getValue(now); ok {
+		if distance := s.rows[len(key)][len(s.query)]; distance <= s.max {
+			fn(key, value, distance)
+		}
+	}
+	n.forEachChild(func(_ byte, child *node[T]) bool {
+		next := append(key, child.prefix...)
+		for depth := len(key) + 1; depth <= len(next); depth++ {
+			if !s.push(next, depth) {
+				return true
+			}
+		}
+		child.fuzzy(s, next, now, fn)
+		return true
+	})
+}
+
+// push computes the row for the first depth bytes of path from the rows before it. It reports whether any distance in the
+// row is within the maximum, since otherwise no extension of the path can be.
+func (s *fuzzySearch) push(path []byte, depth int) bool {
+	if len(s.rows) <= depth {
+		s.rows = append(s.rows, make([]int, len(s.query)+1))
+	}
+	row, prev := s.rows[depth], s.rows[depth-1]
+	c := path[depth-1]
+	row[0] = depth
+	best := depth
+	for i := 1; i <= len(s.query); i++ {
+		cost := 1
+		if s.query[i-1] == c {
+			cost = 0
+		}
+		d := prev[i-1] + cost
+		if prev[i]+1 < d {
+			d = prev[i] + 1
+		}
+		if row[i-1]+1 < d {
+			d = row[i-1] + 1
+		}
+		if s.transpositions && depth > 1 && i > 1 && s.query[i-1] == path[depth-2] && s.query[i-2] == c {
+			if t := s.rows[depth-2][i-2] + 1; t < d {
+				d = t
+			}
+		}
+		row[i] = d
+		if d < best {
+			best = d
+		}
+	}
+	return best <= s.max
+}
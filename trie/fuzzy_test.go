@@ -0,0 +1,173 @@
+package trie
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// editDistance returns the Levenshtein distance between a and b, or their optimal string alignment distance if transpositions is set.
+func editDistance(a, b string, transpositions bool) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = d[i-1][j-1] + cost
+			if d[i-1][j]+1 < d[i][j] {
+				d[i][j] = d[i-1][j] + 1
+			}
+			if d[i][j-1]+1 < d[i][j] {
+				d[i][j] = d[i][j-1] + 1
+			}
+			if transpositions && i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func TestFuzzyFind(t *testing.T) {
+	trie := NewTree[int]()
+	for i, key := range []string{"hello", "help", "hell", "hallo", "world", "yellow", "he"} {
+		trie.Insert(key, i)
+	}
+
+	matches := trie.FuzzyFind("helo", 1)
+	expected := []FuzzyMatch[int]{
+		{"hell", 2, 1},
+		{"hello", 0, 1},
+		{"help", 1, 1},
+	}
+	if !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected %v, got %v", expected, matches)
+	}
+
+	matches = trie.FuzzyFind("hello", 0)
+	if len(matches) != 1 || matches[0].Key != "hello" || matches[0].Distance != 0 {
+		t.Errorf("expected only hello at distance 0, got %v", matches)
+	}
+	if matches := trie.FuzzyFind("hello", -1); matches != nil {
+		t.Errorf("expected no matches for a negative distance, got %v", matches)
+	}
+}
+
+func TestFuzzyFindDamerau(t *testing.T) {
+	trie := NewTree[int]()
+	trie.Insert("hlelo", 0)
+
+	if matches := trie.FuzzyFind("hello", 1); len(matches) != 0 {
+		t.Errorf("expected a transposition to cost 2 without Damerau, got %v", matches)
+	}
+	matches := trie.FuzzyFindDamerau("hello", 1)
+	if len(matches) != 1 || matches[0].Distance != 1 {
+		t.Errorf("expected a transposition to cost 1 with Damerau, got %v", matches)
+	}
+}
+
+func TestFuzzyFindSkipsExpired(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[int](WithClock(clock))
+	trie.InsertWithExpiry("hello", 1, time.Minute)
+	trie.Insert("hallo", 2)
+	clock.Advance(time.Hour)
+	matches := trie.FuzzyFind("hello", 1)
+	if len(matches) != 1 || matches[0].Key != "hallo" {
+		t.Errorf("expected only hallo, got %v", matches)
+	}
+}
+
+func TestFuzzyFindRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomWord := func() string {
+		var sb strings.Builder
+		for i := rng.Intn(8); i >= 0; i-- {
+			sb.WriteByte("abcd"[rng.Intn(4)])
+		}
+		return sb.String()
+	}
+	trie := NewTree[int]()
+	for i := 0; i < 500; i++ {
+		trie.Insert(randomWord(), i)
+	}
+
+	for i := 0; i < 100; i++ {
+		query := randomWord()
+		maxDistance := rng.Intn(4)
+		for _, transpositions := range []bool{false, true} {
+			var expected []FuzzyMatch[int]
+			trie.Walk(func(key string, value int) bool {
+				if d := editDistance(key, query, transpositions); d <= maxDistance {
+					expected = append(expected, FuzzyMatch[int]{key, value, d})
+				}
+				return true
+			})
+			sort.SliceStable(expected, func(i, j int) bool {
+				return expected[i].Distance < expected[j].Distance
+			})
+			matches := trie.fuzzyFind(query, maxDistance, transpositions)
+			if !reflect.DeepEqual(matches, expected) {
+				t.Fatalf("query %q within %d (transpositions=%v): expected %v, got %v", query, maxDistance, transpositions, expected, matches)
+			}
+		}
+	}
+}
+
+// dictionary returns a Tree holding n random lowercase words of 3 to 12 letters.
+func dictionary(n int) Tree[int] {
+	rng := rand.New(rand.NewSource(1))
+	trie := NewTree[int]()
+	for i := 0; i < n; i++ {
+		word := make([]byte, 3+rng.Intn(10))
+		for j := range word {
+			word[j] = byte('a' + rng.Intn(26))
+		}
+		trie.Insert(string(word), i)
+	}
+	return trie
+}
+
+func BenchmarkFuzzyFind(b *testing.B) {
+	trie := dictionary(200000)
+	for _, distance := range []int{1, 2} {
+		b.Run(fmt.Sprintf("distance=%d", distance), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				trie.FuzzyFind("dictionary", distance)
+			}
+		})
+	}
+	b.Run("damerau", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			trie.FuzzyFindDamerau("dictionary", 2)
+		}
+	})
+}
+
+func BenchmarkFuzzyFindLinearScan(b *testing.B) {
+	trie := dictionary(200000)
+	var keys []string
+	trie.Walk(func(key string, _ int) bool {
+		keys = append(keys, key)
+		return true
+	})
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, key := range keys {
+			editDistance(key, "dictionary", false)
+		}
+	}
+}
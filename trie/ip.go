@@ -0,0 +1,220 @@
+package trie
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// IPTree is a Trie of IP prefixes, such as allow and deny lists or GeoIP ranges, that finds the longest stored prefix
+// containing an address. It stores IPv4 and IPv6 prefixes side by side.
+//
+// Every prefix is filed in an underlying Tree under a key made of its address family and then its bits, one byte per bit.
+// Since the Tree is path-compressed, it acts as a binary Patricia trie, and it brings its expiry, eviction callbacks and, with
+// NewConcurrentIPTree, its locking along. The keys reported to OnEvict callbacks are these encoded keys; IPTreeKey turns
+// them back into prefixes.
+type IPTree[T any] struct {
+	tree Tree[T]
+}
+
+// IPEntry is a prefix and its value stored in an IPTree.
+type IPEntry[T any] struct {
+	Prefix netip.Prefix
+	Value  T
+}
+
+// NewIPTree creates and returns a new non-thread-safe IPTree instance. The options apply to the underlying Tree.
+func NewIPTree[T any](opts ...Option) *IPTree[T] {
+	o := newOptions(opts)
+	o.check("NewIPTree", scopeClock|scopeTree)
+	return &IPTree[T]{tree: newTree[T](o, false)}
+}
+
+// NewConcurrentIPTree creates and returns a new thread-safe IPTree instance. The options apply to the underlying Tree.
+func NewConcurrentIPTree[T any](opts ...Option) *IPTree[T] {
+	o := newOptions(opts)
+	o.check("NewConcurrentIPTree", scopeClock|scopeTree)
+	return &IPTree[T]{tree: newTree[T](o, true)}
+}
+
+// Insert adds a prefix and its value to the Trie. The host bits of the prefix are ignored, so 10.1.2.3/8 is stored as
+// 10.0.0.0/8. An IPv4-mapped IPv6 prefix of at least 96 bits is stored as the IPv4 prefix it maps, so ::ffff:10.0.0.0/104
+// is stored as 10.0.0.0/8 and matches the addresses Lookup unmaps; shorter ones stay IPv6 prefixes. It returns the old value (if any) and a boolean indicating if a value was replaced. It panics if the prefix
+// is not valid.
+func (t *IPTree[T]) Insert(prefix netip.Prefix, value T) (oldValue T, replaced bool) {
+	return t.tree.Insert(mustPrefixKey(prefix), value)
+}
+
+// InsertWithExpiry is like Insert, but the value expires after the given duration.
+func (t *IPTree[T]) InsertWithExpiry(prefix netip.Prefix, value T, expiry time.Duration) (oldValue T, replaced bool) {
+	return t.tree.InsertWithExpiry(mustPrefixKey(prefix), value, expiry)
+}
+
+// Find returns the value stored under exactly the given prefix. It returns false if there is none, it has expired, or the
+// prefix is not valid.
+func (t *IPTree[T]) Find(prefix netip.Prefix) (value T, found bool) {
+	key, ok := prefixKey(prefix)
+	if !ok {
+		return value, false
+	}
+	return t.tree.Find(key)
+}
+
+// Remove deletes the given prefix from the Trie. It returns the old value (if any) and a boolean indicating if a value was
+// removed.
+func (t *IPTree[T]) Remove(prefix netip.Prefix) (oldValue T, removed bool) {
+	key, ok := prefixKey(prefix)
+	if !ok {
+		return oldValue, false
+	}
+	return t.tree.Remove(key)
+}
+
+// Lookup returns the longest non-expired prefix containing addr, together with its value. IPv4-mapped IPv6 addresses are
+// looked up as IPv4 addresses, just as IPv4-mapped prefixes are stored as IPv4 prefixes. found is false if no stored prefix contains addr.
+func (t *IPTree[T]) Lookup(addr netip.Addr) (prefix netip.Prefix, value T, found bool) {
+	if !addr.IsValid() {
+		return prefix, value, false
+	}
+	addr = addr.Unmap().WithZone("")
+	key := addrKey(addr, addr.BitLen())
+	t.tree.prefixesOf(key, func(length int, v T) bool {
+		if length > 0 {
+			prefix, value, found = netip.PrefixFrom(addr, length-1).Masked(), v, true
+		}
+		return true
+	})
+	return prefix, value, found
+}
+
+// Contains reports whether a non-expired prefix in the Trie contains addr.
+func (t *IPTree[T]) Contains(addr netip.Addr) bool {
+	_, _, found := t.Lookup(addr)
+	return found
+}
+
+// Covering returns the non-expired entries whose prefixes contain the given prefix, including the prefix itself, from the
+// shortest to the longest.
+func (t *IPTree[T]) Covering(prefix netip.Prefix) []IPEntry[T] {
+	key, ok := prefixKey(prefix)
+	if !ok {
+		return nil
+	}
+	addr := unmapPrefix(prefix).Addr()
+	var entries []IPEntry[T]
+	t.tree.prefixesOf(key, func(length int, value T) bool {
+		if length > 0 {
+			entries = append(entries, IPEntry[T]{Prefix: netip.PrefixFrom(addr, length-1).Masked(), Value: value})
+		}
+		return true
+	})
+	return entries
+}
+
+// Covered returns the non-expired entries whose prefixes are contained in the given prefix, including the prefix itself.
+// Entries are ordered by address, and a prefix comes before the longer prefixes it contains. If limit is greater than zero,
+// at most limit entries are returned.
+func (t *IPTree[T]) Covered(prefix netip.Prefix, limit int) []IPEntry[T] {
+	key, ok := prefixKey(prefix)
+	if !ok {
+		return nil
+	}
+	var entries []IPEntry[T]
+	t.tree.walkPrefix(key, func(key []byte, value T) bool {
+		entries = append(entries, IPEntry[T]{Prefix: keyPrefix(key), Value: value})
+		return limit <= 0 || len(entries) < limit
+	})
+	return entries
+}
+
+// Walk calls fn for every non-expired prefix in the Trie and its value, IPv4 prefixes first, in the order of Covered. Walking
+// stops as soon as fn returns false. fn is called while the Trie is locked for reading, so it must not modify the Trie.
+func (t *IPTree[T]) Walk(fn func(prefix netip.Prefix, value T) bool) {
+	t.tree.walkPrefix("", func(key []byte, value T) bool {
+		return fn(keyPrefix(key), value)
+	})
+}
+
+// Len returns the number of prefixes in the Trie, including expired ones that have not been reclaimed yet.
+func (t *IPTree[T]) Len() int {
+	return t.tree.Len()
+}
+
+// Sweep removes all expired prefixes from the Trie and returns how many were removed.
+func (t *IPTree[T]) Sweep() int {
+	return t.tree.Sweep()
+}
+
+// IPTreeKey returns the prefix stored in an IPTree under key, as reported to OnEvict callbacks. ok is false if key is not a
+// key of an IPTree.
+func IPTreeKey(key string) (prefix netip.Prefix, ok bool) {
+	if len(key) == 0 || (key[0] != '4' && key[0] != '6') {
+		return prefix, false
+	}
+	if (key[0] == '4' && len(key) > 33) || len(key) > 129 {
+		return prefix, false
+	}
+	for i := 1; i < len(key); i++ {
+		if key[i] != '0' && key[i] != '1' {
+			return prefix, false
+		}
+	}
+	return keyPrefix([]byte(key)), true
+}
+
+// prefixKey returns the key a prefix is stored under: '4' or '6' for its address family, followed by its leading bits as
+// '0' and '1' bytes. IPv4-mapped prefixes are stored as IPv4 prefixes. ok is false if the prefix is not valid.
+func prefixKey(prefix netip.Prefix) (key string, ok bool) {
+	if !prefix.IsValid() {
+		return "", false
+	}
+	prefix = unmapPrefix(prefix)
+	return addrKey(prefix.Addr(), prefix.Bits()), true
+}
+
+// unmapPrefix returns the IPv4 prefix an IPv4-mapped IPv6 prefix of at least 96 bits maps, and any other prefix unchanged.
+func unmapPrefix(prefix netip.Prefix) netip.Prefix {
+	if !prefix.Addr().Is4In6() || prefix.Bits() < 96 {
+		return prefix
+	}
+	return netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+}
+
+// mustPrefixKey is like prefixKey, but panics if the prefix is not valid.
+func mustPrefixKey(prefix netip.Prefix) string {
+	key, ok := prefixKey(prefix)
+	if !ok {
+		panic(fmt.Sprintf("trie: invalid IP prefix %v", prefix))
+	}
+	return key
+}
+
+// addrKey returns the key of the prefix made of the first bits bits of addr.
+func addrKey(addr netip.Addr, bits int) string {
+	key := make([]byte, 1+bits)
+	key[0] = '6'
+	if addr.Is4() {
+		key[0] = '4'
+	}
+	octets := addr.As16()
+	offset := 0
+	if addr.Is4() {
+		offset = 12
+	}
+	for i := 0; i < bits; i++ {
+		key[1+i] = '0' + (octets[offset+i/8]>>(7-i%8))&1
+	}
+	return string(key)
+}
+
+// keyPrefix returns the prefix stored under key, which must be a valid key.
+func keyPrefix(key []byte) netip.Prefix {
+	var octets [16]byte
+	for i, bit := range key[1:] {
+		octets[i/8] |= (bit - '0') << (7 - i%8)
+	}
+	if key[0] == '4' {
+		return netip.PrefixFrom(netip.AddrFrom4([4]byte{octets[0], octets[1], octets[2], octets[3]}), len(key)-1)
+	}
+	return netip.PrefixFrom(netip.AddrFrom16(octets), len(key)-1)
+}
@@ -0,0 +1,229 @@
+package trie
+
+import (
+	"math/rand"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIPTreeLookup(t *testing.T) {
+	ips := NewIPTree[string]()
+	ips.Insert(netip.MustParsePrefix("10.0.0.0/8"), "private")
+	ips.Insert(netip.MustParsePrefix("10.1.0.0/16"), "office")
+	ips.Insert(netip.MustParsePrefix("10.1.2.3/32"), "printer")
+	ips.Insert(netip.MustParsePrefix("2001:db8::/32"), "documentation")
+	ips.Insert(netip.MustParsePrefix("::/0"), "any v6")
+
+	tests := []struct {
+		addr   string
+		prefix string
+		value  string
+	}{
+		{"10.200.0.1", "10.0.0.0/8", "private"},
+		{"10.1.9.9", "10.1.0.0/16", "office"},
+		{"10.1.2.3", "10.1.2.3/32", "printer"},
+		{"2001:db8:1::1", "2001:db8::/32", "documentation"},
+		{"2001:db9::1", "::/0", "any v6"},
+		// IPv4-mapped addresses are looked up as IPv4
+		{"::ffff:10.1.2.3", "10.1.2.3/32", "printer"},
+	}
+	for _, test := range tests {
+		prefix, value, found := ips.Lookup(netip.MustParseAddr(test.addr))
+		if !found || prefix.String() != test.prefix || value != test.value {
+			t.Errorf("%s: expected %s %s, got %v %s %v", test.addr, test.prefix, test.value, prefix, value, found)
+		}
+	}
+
+	// IPv6 prefixes never contain IPv4 addresses, and the other way round
+	if _, _, found := ips.Lookup(netip.MustParseAddr("192.168.0.1")); found {
+		t.Errorf("expected no match for 192.168.0.1")
+	}
+	if ips.Contains(netip.Addr{}) {
+		t.Errorf("expected no match for the zero address")
+	}
+}
+
+func TestIPTreeInsertAndRemove(t *testing.T) {
+	ips := NewIPTree[int]()
+
+	// host bits are ignored
+	ips.Insert(netip.MustParsePrefix("192.168.1.77/24"), 1)
+	if value, found := ips.Find(netip.MustParsePrefix("192.168.1.0/24")); !found || value != 1 {
+		t.Errorf("expected 1, got %d %v", value, found)
+	}
+	if old, replaced := ips.Insert(netip.MustParsePrefix("192.168.1.0/24"), 2); !replaced || old != 1 {
+		t.Errorf("expected to replace 1, got %d %v", old, replaced)
+	}
+	if _, found := ips.Find(netip.MustParsePrefix("192.168.1.0/25")); found {
+		t.Errorf("expected no value for a longer prefix")
+	}
+	if ips.Len() != 1 {
+		t.Errorf("expected 1 prefix, got %d", ips.Len())
+	}
+
+	if old, removed := ips.Remove(netip.MustParsePrefix("192.168.1.0/24")); !removed || old != 2 {
+		t.Errorf("expected to remove 2, got %d %v", old, removed)
+	}
+	if _, removed := ips.Remove(netip.Prefix{}); removed {
+		t.Errorf("expected removing an invalid prefix to fail")
+	}
+	if ips.Len() != 0 || ips.Contains(netip.MustParseAddr("192.168.1.1")) {
+		t.Errorf("expected an empty tree")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected inserting an invalid prefix to panic")
+		}
+	}()
+	ips.Insert(netip.Prefix{}, 0)
+}
+
+func TestIPTreeMappedPrefixes(t *testing.T) {
+	ips := NewIPTree[int]()
+
+	// an IPv4-mapped prefix is stored as the IPv4 prefix it maps
+	ips.Insert(netip.MustParsePrefix("::ffff:10.0.0.0/104"), 1)
+	if prefix, value, found := ips.Lookup(netip.MustParseAddr("10.1.2.3")); !found || value != 1 || prefix != netip.MustParsePrefix("10.0.0.0/8") {
+		t.Errorf("expected 10.0.0.0/8=1, got %v=%d %v", prefix, value, found)
+	}
+	if _, value, found := ips.Lookup(netip.MustParseAddr("::ffff:10.1.2.3")); !found || value != 1 {
+		t.Errorf("expected the mapped address to match, got %d %v", value, found)
+	}
+	if old, replaced := ips.Insert(netip.MustParsePrefix("10.0.0.0/8"), 2); !replaced || old != 1 {
+		t.Errorf("expected to replace 1, got %d %v", old, replaced)
+	}
+	covering := ips.Covering(netip.MustParsePrefix("::ffff:10.1.0.0/112"))
+	if len(covering) != 1 || covering[0].Prefix != netip.MustParsePrefix("10.0.0.0/8") {
+		t.Errorf("expected [10.0.0.0/8], got %v", covering)
+	}
+
+	// shorter mapped prefixes also cover IPv6 addresses, so they stay IPv6 prefixes
+	ips.Insert(netip.MustParsePrefix("::ffff:0:0/80"), 3)
+	if value, found := ips.Find(netip.MustParsePrefix("::ffff:0:0/80")); !found || value != 3 {
+		t.Errorf("expected 3, got %d %v", value, found)
+	}
+	if ips.Len() != 2 {
+		t.Errorf("expected 2 prefixes, got %d", ips.Len())
+	}
+}
+
+func TestIPTreeCoveringAndCovered(t *testing.T) {
+	ips := NewIPTree[int]()
+	for i, prefix := range []string{"0.0.0.0/0", "10.0.0.0/8", "10.0.0.0/16", "10.0.128.0/17", "10.1.0.0/16", "11.0.0.0/8", "::/0"} {
+		ips.Insert(netip.MustParsePrefix(prefix), i)
+	}
+
+	prefixes := func(entries []IPEntry[int]) []string {
+		var result []string
+		for _, entry := range entries {
+			result = append(result, entry.Prefix.String())
+		}
+		return result
+	}
+	covering := prefixes(ips.Covering(netip.MustParsePrefix("10.0.200.0/24")))
+	if expected := []string{"0.0.0.0/0", "10.0.0.0/8", "10.0.0.0/16", "10.0.128.0/17"}; !reflect.DeepEqual(covering, expected) {
+		t.Errorf("expected %v, got %v", expected, covering)
+	}
+	covered := prefixes(ips.Covered(netip.MustParsePrefix("10.0.0.0/8"), 0))
+	if expected := []string{"10.0.0.0/8", "10.0.0.0/16", "10.0.128.0/17", "10.1.0.0/16"}; !reflect.DeepEqual(covered, expected) {
+		t.Errorf("expected %v, got %v", expected, covered)
+	}
+	if covered := ips.Covered(netip.MustParsePrefix("10.0.0.0/8"), 2); len(covered) != 2 {
+		t.Errorf("expected 2 entries with a limit, got %d", len(covered))
+	}
+
+	var walked []string
+	ips.Walk(func(prefix netip.Prefix, value int) bool {
+		walked = append(walked, prefix.String())
+		return true
+	})
+	if expected := []string{"0.0.0.0/0", "10.0.0.0/8", "10.0.0.0/16", "10.0.128.0/17", "10.1.0.0/16", "11.0.0.0/8", "::/0"}; !reflect.DeepEqual(walked, expected) {
+		t.Errorf("expected %v, got %v", expected, walked)
+	}
+}
+
+func TestIPTreeExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	var evicted []string
+	ips := NewConcurrentIPTree[string](WithClock(clock), WithOnEvict(func(key string, value string, reason EvictReason) {
+		evicted = append(evicted, key)
+	}))
+	ips.Insert(netip.MustParsePrefix("10.0.0.0/8"), "allow")
+	ips.InsertWithExpiry(netip.MustParsePrefix("10.6.6.0/24"), "deny", time.Minute)
+
+	if _, value, _ := ips.Lookup(netip.MustParseAddr("10.6.6.6")); value != "deny" {
+		t.Errorf("expected deny before expiry, got %s", value)
+	}
+
+	// an expired prefix no longer shadows the shorter one
+	clock.Advance(2 * time.Minute)
+	if _, value, _ := ips.Lookup(netip.MustParseAddr("10.6.6.6")); value != "allow" {
+		t.Errorf("expected allow after expiry, got %s", value)
+	}
+	if removed := ips.Sweep(); removed != 1 {
+		t.Errorf("expected 1 expired prefix to be swept, got %d", removed)
+	}
+	if len(evicted) != 1 {
+		t.Fatalf("expected 1 eviction, got %v", evicted)
+	}
+	if prefix, ok := IPTreeKey(evicted[0]); !ok || prefix != netip.MustParsePrefix("10.6.6.0/24") {
+		t.Errorf("expected the evicted key to decode to 10.6.6.0/24, got %v %v", prefix, ok)
+	}
+	for _, key := range []string{"", "x", "4012", "4" + string(make([]byte, 33))} {
+		if _, ok := IPTreeKey(key); ok {
+			t.Errorf("expected %q not to decode", key)
+		}
+	}
+}
+
+func TestIPTreeRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomAddr := func() netip.Addr {
+		if rnd.Intn(2) == 0 {
+			return netip.AddrFrom4([4]byte{10, byte(rnd.Intn(4)), byte(rnd.Intn(256)), byte(rnd.Intn(256))})
+		}
+		var b [16]byte
+		b[0], b[1], b[2] = 0x20, 0x01, byte(rnd.Intn(4))
+		b[15] = byte(rnd.Intn(256))
+		return netip.AddrFrom16(b)
+	}
+	ips := NewIPTree[int]()
+	var stored []netip.Prefix
+	for i := 0; i < 500; i++ {
+		addr := randomAddr()
+		prefix := netip.PrefixFrom(addr, rnd.Intn(addr.BitLen()+1)).Masked()
+		if _, replaced := ips.Insert(prefix, i); !replaced {
+			stored = append(stored, prefix)
+		}
+	}
+	for i := 0; i < 2000; i++ {
+		addr := randomAddr()
+		var expected netip.Prefix
+		for _, prefix := range stored {
+			if prefix.Contains(addr) && (!expected.IsValid() || prefix.Bits() > expected.Bits()) {
+				expected = prefix
+			}
+		}
+		prefix, _, found := ips.Lookup(addr)
+		if found != expected.IsValid() || prefix != expected {
+			t.Fatalf("%v: expected %v, got %v %v", addr, expected, prefix, found)
+		}
+	}
+}
+
+func BenchmarkIPTreeLookup(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	ips := NewIPTree[int]()
+	for i := 0; i < 100000; i++ {
+		addr := netip.AddrFrom4([4]byte{byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), 0})
+		ips.Insert(netip.PrefixFrom(addr, 8+rnd.Intn(17)).Masked(), i)
+	}
+	addr := netip.MustParseAddr("192.168.1.1")
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		ips.Lookup(addr)
+	}
+}
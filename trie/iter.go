@@ -0,0 +1,61 @@
+package trie
+
+import (
+	"bytes"
+	"time"
+)
+
+// Walk calls fn for every non-expired entry in the Trie, in lexicographic byte order of the keys. Walking stops as soon as fn returns false.
+// fn is called while the Tree is locked for reading, so it must not modify the Tree.
+func (t *Tree[T]) Walk(fn func(key string, value T) bool) {
+	t.Range("", "", fn)
+}
+
+// Range calls fn for every non-expired entry whose key lies in the half-open range [from, to), in lexicographic byte order of the keys.
+// An empty to means the range has no upper bound. Walking stops as soon as fn returns false.
+// fn is called while the Tree is locked for reading, so it must not modify the Tree.
+func (t *Tree[T]) Range(from, to string, fn func(key string, value T) bool) {
+	if t.syncSafe {
+		t.lock.RLock()
+		defer t.lock.RUnlock()
+	}
+	t.root.rangeOver(from, to, t.now(), fn)
+}
+
+// rangeOver calls fn for every value that is not expired at now in the subtree rooted at n whose key lies in [from, to), in
+// lexicographic byte order. An empty to means the range has no upper bound.
+func (n *node[T]) rangeOver(from, to string, now time.Time, fn func(key string, value T) bool) {
+	var upper []byte
+	if to != "" {
+		upper = []byte(to)
+	}
+	n.walkRange(make([]byte, 0, 64), []byte(from), upper, now, func(key []byte, value T) bool {
+		return fn(string(key), value)
+	})
+}
+
+// walkRange calls fn for every value that is not expired at now in the subtree rooted at n whose key lies in [from, to), in lexicographic byte order.
+// A nil to means the range has no upper bound. It returns false once the walk has been stopped, either by fn or by reaching to.
+func (n *node[T]) walkRange(key, from, to []byte, now time.Time, fn func(key []byte, value T) bool) bool {
+	if val, ok := n.getValue(now); ok && bytes.Compare(key, from) >= 0 {
+		if !fn(key, val) {
+			return false
+		}
+	}
+	return n.forEachChild(func(_ byte, child *node[T]) bool {
+		next := append(key, child.prefix...)
+		// every key in the subtree of child starts with next, so nothing from here on can be below to
+		if to != nil && bytes.Compare(next, to) >= 0 {
+			return false
+		}
+		// the whole subtree sorts before from if next sorts before from on their common length
+		common := len(next)
+		if len(from) < common {
+			common = len(from)
+		}
+		if bytes.Compare(next[:common], from[:common]) < 0 {
+			return true
+		}
+		return child.walkRange(next, from, to, now, fn)
+	})
+}
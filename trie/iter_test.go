@@ -0,0 +1,207 @@
+package trie
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWalkOrder(t *testing.T) {
+	trie := NewTree[int]()
+	keys := []string{"banana", "apple", "app", "", "cherry", "b", "apricot", "\xff", "a\x00"}
+	for i, key := range keys {
+		trie.Insert(key, i)
+	}
+
+	var walked []string
+	trie.Walk(func(key string, value int) bool {
+		walked = append(walked, key)
+		return true
+	})
+
+	expected := append([]string(nil), keys...)
+	sort.Strings(expected)
+	if !reflect.DeepEqual(walked, expected) {
+		t.Errorf("expected keys=%q, got keys=%q", expected, walked)
+	}
+}
+
+func TestWalkRandomKeys(t *testing.T) {
+	trie := NewTree[int]()
+	rng := rand.New(rand.NewSource(1))
+	seen := map[string]bool{}
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("%x", rng.Int63n(1<<20))
+		trie.Insert(key, i)
+		seen[key] = true
+	}
+
+	expected := make([]string, 0, len(seen))
+	for key := range seen {
+		expected = append(expected, key)
+	}
+	sort.Strings(expected)
+
+	var walked []string
+	trie.Walk(func(key string, _ int) bool {
+		walked = append(walked, key)
+		return true
+	})
+	if !reflect.DeepEqual(walked, expected) {
+		t.Errorf("walk did not return the keys in sorted order")
+	}
+}
+
+func TestWalkEarlyStop(t *testing.T) {
+	trie := NewTree[int]()
+	for i := 0; i < 10; i++ {
+		trie.Insert(fmt.Sprint(i), i)
+	}
+
+	var walked []string
+	trie.Walk(func(key string, _ int) bool {
+		walked = append(walked, key)
+		return len(walked) < 3
+	})
+	if !reflect.DeepEqual(walked, []string{"0", "1", "2"}) {
+		t.Errorf("expected keys=[0 1 2], got keys=%v", walked)
+	}
+}
+
+func TestWalkSkipsExpired(t *testing.T) {
+	trie := NewTree[string]()
+	trie.Insert("a", "alive")
+	trie.InsertWithExpiry("b", "expired", -time.Second)
+	trie.InsertWithExpiry("c", "alive", time.Hour)
+
+	var walked []string
+	trie.Walk(func(key string, _ string) bool {
+		walked = append(walked, key)
+		return true
+	})
+	if !reflect.DeepEqual(walked, []string{"a", "c"}) {
+		t.Errorf("expected keys=[a c], got keys=%v", walked)
+	}
+}
+
+func TestRange(t *testing.T) {
+	trie := NewTree[int]()
+	keys := []string{"a", "ab", "abc", "b", "ba", "bb", "c", "ca"}
+	for i, key := range keys {
+		trie.Insert(key, i)
+	}
+
+	tests := []struct {
+		from, to string
+		expected []string
+	}{
+		{"", "", keys},
+		{"ab", "b", []string{"ab", "abc"}},
+		{"aa", "bb", []string{"ab", "abc", "b", "ba"}},
+		{"b", "", []string{"b", "ba", "bb", "c", "ca"}},
+		{"", "b", []string{"a", "ab", "abc"}},
+		{"abd", "ba", []string{"b"}},
+		{"c", "c", nil},
+		{"d", "", nil},
+	}
+
+	for _, test := range tests {
+		var ranged []string
+		trie.Range(test.from, test.to, func(key string, _ int) bool {
+			ranged = append(ranged, key)
+			return true
+		})
+		if !reflect.DeepEqual(ranged, test.expected) {
+			t.Errorf("range [%q, %q): expected keys=%v, got keys=%v", test.from, test.to, test.expected, ranged)
+		}
+	}
+}
+
+func TestRangeRandomBounds(t *testing.T) {
+	trie := NewTree[int]()
+	rng := rand.New(rand.NewSource(1))
+	randomKey := func() string {
+		key := make([]byte, rng.Intn(6))
+		for i := range key {
+			key[i] = "abc"[rng.Intn(3)]
+		}
+		return string(key)
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 300; i++ {
+		key := randomKey()
+		trie.Insert(key, i)
+		seen[key] = true
+	}
+	sorted := make([]string, 0, len(seen))
+	for key := range seen {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	for i := 0; i < 500; i++ {
+		from, to := randomKey(), randomKey()
+		var expected []string
+		for _, key := range sorted {
+			if key >= from && (to == "" || key < to) {
+				expected = append(expected, key)
+			}
+		}
+		var ranged []string
+		trie.Range(from, to, func(key string, _ int) bool {
+			ranged = append(ranged, key)
+			return true
+		})
+		if !reflect.DeepEqual(ranged, expected) {
+			t.Fatalf("range [%q, %q): expected keys=%v, got keys=%v", from, to, expected, ranged)
+		}
+	}
+}
+
+func TestRangeConcurrency(t *testing.T) {
+	trie := NewConcurrentTree[int]()
+
+	done := make(chan bool)
+
+	// Concurrent inserts
+	go func() {
+		for i := 0; i < 1000; i++ {
+			trie.Insert(fmt.Sprintf("%04d", i), i)
+		}
+		done <- true
+	}()
+
+	// Concurrent range scans
+	go func() {
+		for i := 0; i < 100; i++ {
+			prev := ""
+			trie.Range("0100", "0200", func(key string, _ int) bool {
+				if key <= prev {
+					t.Errorf("range returned %q after %q", key, prev)
+				}
+				prev = key
+				return true
+			})
+		}
+		done <- true
+	}()
+
+	<-done
+	<-done
+}
+
+func BenchmarkWalk(b *testing.B) {
+	trie := NewTree[string]()
+	for n := 0; n < 10000; n++ {
+		trie.Insert(fmt.Sprint(n), "value")
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		trie.Walk(func(string, string) bool { return true })
+	}
+}
@@ -0,0 +1,403 @@
+package trie
+
+import (
+	"time"
+)
+
+// Wildcards configures the pattern syntax understood by Match and MatchPatterns. A zero byte disables a wildcard. There is no
+// escaping: a wildcard byte appearing where its wildcard is not allowed matches itself.
+type Wildcards struct {
+	// Separator splits keys into segments. Only Rest matches it. Zero means keys consist of a single segment.
+	Separator byte
+	// Segment matches exactly one segment, which may be empty, like '+' in MQTT topic filters. It is only a wildcard when it
+	// makes up a whole segment of the pattern.
+	Segment byte
+	// Rest matches any number of trailing segments, like '#' in MQTT topic filters. It is only a wildcard when it makes up the
+	// last segment of the pattern, and then also matches the parent segment: "a/#" matches "a".
+	Rest byte
+	// Star matches any run of bytes within a segment, like '*' in shell globs.
+	Star byte
+	// Question matches any single byte within a segment, like '?' in shell globs.
+	Question byte
+	// Classes enables bracket expressions matching a single byte within a segment, like [abc], [a-z] or the negated [!0-9]
+	// and [^0-9] in shell globs. A '[' without a closing ']' matches itself.
+	Classes bool
+}
+
+var (
+	// MQTTWildcards are the wildcards of MQTT topic filters: '+' matches one level and '#' all remaining levels.
+	MQTTWildcards = Wildcards{Separator: '/', Segment: '+', Rest: '#'}
+	// GlobWildcards are the wildcards of shell globs over slash-separated paths: '*', '?' and bracket expressions.
+	GlobWildcards = Wildcards{Separator: '/', Star: '*', Question: '?', Classes: true}
+)
+
+// Match calls fn for every non-expired entry whose key matches pattern, in lexicographic byte order of the keys. Walking stops as
+// soon as fn returns false. The pattern is matched while descending the Trie, so only the branches that can still match are
+// visited. fn is called while the Tree is locked for reading, so it must not modify the Tree.
+func (t *Tree[T]) Match(pattern string, wildcards Wildcards, fn func(key string, value T) bool) {
+	m := &keyMatcher{w: wildcards, tokens: tokenize(pattern, wildcards)}
+	states := make(bitset, (len(m.tokens)+64)/64)
+	states.add(0)
+	m.closure(states)
+	if t.syncSafe {
+		t.lock.RLock()
+		defer t.lock.RUnlock()
+	}
+	t.root.match(m, make([]byte, 0, 64), states, t.now(), func(key []byte, value T) bool {
+		return fn(string(key), value)
+	})
+}
+
+// MatchPatterns treats the keys of the Trie as patterns and calls fn for every non-expired entry whose key matches subject, in
+// lexicographic byte order of the keys. Walking stops as soon as fn returns false. This finds, for example, the subscriptions
+// whose topic filters match a published topic. Only the branches whose patterns can still match are visited. fn is called
+// while the Tree is locked for reading, so it must not modify the Tree.
+func (t *Tree[T]) MatchPatterns(subject string, wildcards Wildcards, fn func(pattern string, value T) bool) {
+	m := newPatternMatcher(subject, wildcards)
+	states := make(bitset, (len(subject)+2+63)/64)
+	states.add(0)
+	if t.syncSafe {
+		t.lock.RLock()
+		defer t.lock.RUnlock()
+	}
+	t.root.matchPatterns(m, make([]byte, 0, 64), lexer{w: wildcards, segmentStart: true}, states, t.now(), func(key []byte, value T) bool {
+		return fn(string(key), value)
+	})
+}
+
+// match calls fn for every value that is not expired at now in the subtree rooted at n whose key matches the pattern of m.
+// key is the path leading to n and states the set of pattern positions that path can reach. It returns false if fn stopped
+// the walk.
+func (n *node[T]) match(m *keyMatcher, key []byte, states bitset, now time.Time, fn func(key []byte, value T) bool) bool {
+	if m.accepts(states) {
+		if value, ok := n.getValue(now); ok && !fn(key, value) {
+			return false
+		}
+	}
+	return n.forEachChild(func(_ byte, child *node[T]) bool {
+		// states is shared with the other children, so the edge is read into buffers of its own
+		cur, buffers := states, [2]bitset{make(bitset, len(states)), make(bitset, len(states))}
+		for i, b := range child.prefix {
+			next := buffers[i%2]
+			m.step(cur, next, b)
+			if next.empty() {
+				return true
+			}
+			cur = next
+		}
+		return child.match(m, append(key, child.prefix...), cur, now, fn)
+	})
+}
+
+// matchPatterns calls fn for every value that is not expired at now in the subtree rooted at n whose key, read as a pattern,
+// matches the subject of m. key is the path leading to n, lex the state of reading it as a pattern, and states the set of
+// subject positions it can match up to. It returns false if fn stopped the walk.
+func (n *node[T]) matchPatterns(m *patternMatcher, key []byte, lex lexer, states bitset, now time.Time, fn func(key []byte, value T) bool) bool {
+	if value, ok := n.getValue(now); ok {
+		final, spare := states.clone(), make(bitset, len(states))
+		lex.end(func(tok token) {
+			final, spare = m.apply(final, spare, tok)
+		})
+		if m.accepts(final) && !fn(key, value) {
+			return false
+		}
+	}
+	return n.forEachChild(func(_ byte, child *node[T]) bool {
+		childLex := lex
+		cur, spare := states.clone(), make(bitset, len(states))
+		emit := func(tok token) {
+			cur, spare = m.apply(cur, spare, tok)
+		}
+		for _, b := range child.prefix {
+			childLex.feed(b, emit)
+			if cur.empty() {
+				return true
+			}
+		}
+		return child.matchPatterns(m, append(key, child.prefix...), childLex, cur, now, fn)
+	})
+}
+
+// tokenKind is the kind of a pattern token.
+type tokenKind uint8
+
+const (
+	tokenLiteral  tokenKind = iota // matches its byte
+	tokenQuestion                  // matches any byte but the separator
+	tokenClass                     // matches the bytes of its class but the separator
+	tokenStar                      // matches any run of bytes without the separator
+	tokenSegment                   // matches one segment, which is any run of bytes without the separator
+	tokenRest                      // matches anything
+)
+
+// token is a single element of a pattern.
+type token struct {
+	kind  tokenKind
+	b     byte       // byte of a literal
+	class *[4]uint64 // bytes matched by a class, as a bitmap
+}
+
+// lexer splits a pattern into tokens one byte at a time, so that a pattern can be read while walking down the Trie. Tokens
+// are delayed until they are certain: a Segment or Rest wildcard until the byte after it shows that it makes up a whole
+// segment, and a bracket expression until it is closed. A lexer is a value that can be copied to branch off.
+type lexer struct {
+	w            Wildcards
+	segmentStart bool      // the next byte starts a segment
+	pending      tokenKind // tokenSegment or tokenRest if the previous byte may be that wildcard, tokenLiteral otherwise
+	inClass      bool      // a bracket expression has been opened
+	class        []byte    // bytes of the open bracket expression, shared between copies and therefore never appended to in place
+}
+
+// feed reads the next byte of the pattern, and passes the tokens it completes to emit.
+func (l *lexer) feed(b byte, emit func(token)) {
+	if l.inClass {
+		body := 0
+		if len(l.class) > 0 && (l.class[0] == '!' || l.class[0] == '^') {
+			body = 1
+		}
+		if b == ']' && len(l.class) > body {
+			emit(token{kind: tokenClass, class: parseClass(l.class)})
+			l.inClass, l.class = false, nil
+			l.segmentStart = false
+			return
+		}
+		l.class = append(l.class[:len(l.class):len(l.class)], b)
+		return
+	}
+	if l.pending != tokenLiteral {
+		pending := l.pending
+		l.pending = tokenLiteral
+		switch {
+		case pending == tokenSegment && l.w.separates(b):
+			emit(token{kind: tokenSegment})
+		case pending == tokenSegment:
+			emit(token{kind: tokenLiteral, b: l.w.Segment})
+		default:
+			emit(token{kind: tokenLiteral, b: l.w.Rest})
+		}
+	}
+	switch {
+	case l.w.Classes && b == '[':
+		l.inClass, l.class = true, nil
+	case l.segmentStart && l.w.Segment != 0 && b == l.w.Segment:
+		l.pending = tokenSegment
+	case l.segmentStart && l.w.Rest != 0 && b == l.w.Rest:
+		l.pending = tokenRest
+	case l.w.Star != 0 && b == l.w.Star:
+		emit(token{kind: tokenStar})
+	case l.w.Question != 0 && b == l.w.Question:
+		emit(token{kind: tokenQuestion})
+	default:
+		emit(token{kind: tokenLiteral, b: b})
+	}
+	l.segmentStart = l.w.separates(b)
+}
+
+// end passes the tokens still held back at the end of the pattern to emit. It leaves l unchanged.
+func (l lexer) end(emit func(token)) {
+	if l.inClass {
+		// the bracket expression was never closed, so its '[' is an ordinary byte
+		emit(token{kind: tokenLiteral, b: '['})
+		rest := lexer{w: l.w}
+		for _, b := range l.class {
+			rest.feed(b, emit)
+		}
+		rest.end(emit)
+		return
+	}
+	if l.pending != tokenLiteral {
+		emit(token{kind: l.pending})
+	}
+}
+
+// tokenize splits a whole pattern into tokens.
+func tokenize(pattern string, w Wildcards) []token {
+	var tokens []token
+	emit := func(tok token) {
+		tokens = append(tokens, tok)
+	}
+	l := lexer{w: w, segmentStart: true}
+	for i := 0; i < len(pattern); i++ {
+		l.feed(pattern[i], emit)
+	}
+	l.end(emit)
+	return tokens
+}
+
+// parseClass returns the bitmap of the bytes matched by the bracket expression with the given body, which excludes the brackets.
+func parseClass(body []byte) *[4]uint64 {
+	var set [4]uint64
+	negated := len(body) > 0 && (body[0] == '!' || body[0] == '^')
+	if negated {
+		body = body[1:]
+	}
+	for i := 0; i < len(body); i++ {
+		lo, hi := body[i], body[i]
+		if i+2 < len(body) && body[i+1] == '-' {
+			hi = body[i+2]
+			i += 2
+		}
+		for c := int(lo); c <= int(hi); c++ {
+			set[c>>6] |= 1 << (c & 63)
+		}
+	}
+	if negated {
+		for i := range set {
+			set[i] = ^set[i]
+		}
+	}
+	return &set
+}
+
+// separates reports whether b is the separator.
+func (w Wildcards) separates(b byte) bool {
+	return w.Separator != 0 && b == w.Separator
+}
+
+// matchesByte reports whether tok, which must match a single byte, matches b.
+func (w Wildcards) matchesByte(tok token, b byte) bool {
+	switch tok.kind {
+	case tokenLiteral:
+		return b == tok.b
+	case tokenQuestion:
+		return !w.separates(b)
+	case tokenClass:
+		return !w.separates(b) && tok.class[b>>6]&(1<<(b&63)) != 0
+	}
+	return false
+}
+
+// keyMatcher matches keys against a pattern, as a nondeterministic automaton whose states are the positions in the tokens of
+// the pattern.
+type keyMatcher struct {
+	w      Wildcards
+	tokens []token
+}
+
+// closure adds to states the positions reachable from them without reading a byte, by matching wildcards with nothing.
+func (m *keyMatcher) closure(states bitset) {
+	for i, tok := range m.tokens {
+		if states.has(i) && (tok.kind == tokenStar || tok.kind == tokenSegment || tok.kind == tokenRest) {
+			states.add(i + 1)
+		}
+	}
+}
+
+// step sets next to the positions reachable from states by reading b.
+func (m *keyMatcher) step(states, next bitset, b byte) {
+	next.reset()
+	for i, tok := range m.tokens {
+		if !states.has(i) {
+			continue
+		}
+		switch tok.kind {
+		case tokenStar, tokenSegment:
+			if !m.w.separates(b) {
+				next.add(i)
+			}
+		case tokenRest:
+			next.add(i)
+		default:
+			if m.w.matchesByte(tok, b) {
+				next.add(i + 1)
+			}
+		}
+	}
+	m.closure(next)
+}
+
+// accepts reports whether a key that reaches states matches the pattern.
+func (m *keyMatcher) accepts(states bitset) bool {
+	n := len(m.tokens)
+	if states.has(n) {
+		return true
+	}
+	// a trailing Rest wildcard also matches the parent segment, without the separator before it
+	return n >= 2 && m.tokens[n-1].kind == tokenRest && m.tokens[n-2].kind == tokenLiteral && m.w.separates(m.tokens[n-2].b) && states.has(n-2)
+}
+
+// patternMatcher matches patterns against a subject, as a nondeterministic automaton whose states are the positions in the
+// subject. The extra state len(subject)+1 means the subject ended right before a separator of the pattern, which only a
+// trailing Rest wildcard can match.
+type patternMatcher struct {
+	w       Wildcards
+	subject string
+	ends    []int // ends[i] is the position of the first separator at or after i, or len(subject)
+}
+
+// newPatternMatcher returns a patternMatcher for subject.
+func newPatternMatcher(subject string, w Wildcards) *patternMatcher {
+	ends := make([]int, len(subject)+1)
+	ends[len(subject)] = len(subject)
+	for i := len(subject) - 1; i >= 0; i-- {
+		if w.separates(subject[i]) {
+			ends[i] = i
+		} else {
+			ends[i] = ends[i+1]
+		}
+	}
+	return &patternMatcher{w: w, subject: subject, ends: ends}
+}
+
+// apply sets spare to the subject positions reachable from states by matching tok, and returns it together with states, which
+// can be reused as the next spare.
+func (m *patternMatcher) apply(states, spare bitset, tok token) (bitset, bitset) {
+	spare.reset()
+	end := len(m.subject)
+	for i := 0; i <= end+1; i++ {
+		if !states.has(i) {
+			continue
+		}
+		switch {
+		case tok.kind == tokenRest:
+			// Rest is always the last segment, so it matches whatever is left
+			spare.add(end)
+		case i > end:
+			// only Rest matches after the subject has ended before a separator
+		case tok.kind == tokenStar || tok.kind == tokenSegment:
+			for j := i; j <= m.ends[i]; j++ {
+				spare.add(j)
+			}
+		case i < end && m.w.matchesByte(tok, m.subject[i]):
+			spare.add(i + 1)
+		case i == end && tok.kind == tokenLiteral && m.w.separates(tok.b):
+			spare.add(end + 1)
+		}
+	}
+	return spare, states
+}
+
+// accepts reports whether a pattern that reaches states matches the subject.
+func (m *patternMatcher) accepts(states bitset) bool {
+	return states.has(len(m.subject))
+}
+
+// bitset is a set of small non-negative integers.
+type bitset []uint64
+
+func (s bitset) add(i int) {
+	s[i>>6] |= 1 << (i & 63)
+}
+
+func (s bitset) has(i int) bool {
+	return s[i>>6]&(1<<(i&63)) != 0
+}
+
+func (s bitset) empty() bool {
+	for _, word := range s {
+		if word != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s bitset) reset() {
+	for i := range s {
+		s[i] = 0
+	}
+}
+
+func (s bitset) clone() bitset {
+	return append(bitset(nil), s...)
+}
@@ -0,0 +1,248 @@
+package trie
+
+import (
+	"fmt"
+	"math/rand"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// matchedKeys returns the keys found by Match.
+func matchedKeys[T any](trie *Tree[T], pattern string, w Wildcards) []string {
+	var keys []string
+	trie.Match(pattern, w, func(key string, _ T) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// matchingPatterns returns the keys found by MatchPatterns.
+func matchingPatterns[T any](trie *Tree[T], subject string, w Wildcards) []string {
+	var keys []string
+	trie.MatchPatterns(subject, w, func(key string, _ T) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+func TestMatchMQTT(t *testing.T) {
+	trie := NewTree[int]()
+	for i, key := range []string{"sport", "sport/", "sport/tennis", "sport/tennis/player1", "sport/tennis/player2", "sport/golf", "news/tennis", "sport+", ""} {
+		trie.Insert(key, i)
+	}
+
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"sport/tennis/player1", []string{"sport/tennis/player1"}},
+		{"sport/+", []string{"sport/", "sport/golf", "sport/tennis"}},
+		{"+/tennis", []string{"news/tennis", "sport/tennis"}},
+		{"sport/#", []string{"sport", "sport/", "sport/golf", "sport/tennis", "sport/tennis/player1", "sport/tennis/player2"}},
+		{"sport/tennis/#", []string{"sport/tennis", "sport/tennis/player1", "sport/tennis/player2"}},
+		{"#", []string{"", "news/tennis", "sport", "sport+", "sport/", "sport/golf", "sport/tennis", "sport/tennis/player1", "sport/tennis/player2"}},
+		{"+", []string{"", "sport", "sport+"}},
+		// wildcards that do not make up a whole segment match themselves
+		{"sport+", []string{"sport+"}},
+		{"sport/#/player1", nil},
+	}
+	for _, test := range tests {
+		if keys := matchedKeys(&trie, test.pattern, MQTTWildcards); !reflect.DeepEqual(keys, test.expected) {
+			t.Errorf("%q: expected %q, got %q", test.pattern, test.expected, keys)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	trie := NewTree[int]()
+	for i, key := range []string{"a.go", "b.go", "bb.go", "c.txt", "dir/a.go", "dir/sub/b.go", "[x]", "x"} {
+		trie.Insert(key, i)
+	}
+
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"*.go", []string{"a.go", "b.go", "bb.go"}},
+		{"?.go", []string{"a.go", "b.go"}},
+		{"[a-b].go", []string{"a.go", "b.go"}},
+		{"[!a].*", []string{"b.go", "c.txt"}},
+		{"*/*.go", []string{"dir/a.go"}},
+		{"dir/*/*", []string{"dir/sub/b.go"}},
+		{"*b*", []string{"b.go", "bb.go"}},
+		{"[x]", []string{"x"}},
+		// an unclosed bracket matches itself
+		{"[x", nil},
+		{"[]", nil},
+	}
+	for _, test := range tests {
+		if keys := matchedKeys(&trie, test.pattern, GlobWildcards); !reflect.DeepEqual(keys, test.expected) {
+			t.Errorf("%q: expected %q, got %q", test.pattern, test.expected, keys)
+		}
+	}
+}
+
+func TestMatchPatternsMQTT(t *testing.T) {
+	subscriptions := NewTree[string]()
+	for _, filter := range []string{"sport/tennis/player1", "sport/tennis/+", "sport/#", "#", "+/+", "+/tennis/#", "sport/+/player1", "news/#", "sport+"} {
+		subscriptions.Insert(filter, "client:"+filter)
+	}
+
+	tests := []struct {
+		topic    string
+		expected []string
+	}{
+		{"sport/tennis/player1", []string{"#", "+/tennis/#", "sport/#", "sport/+/player1", "sport/tennis/+", "sport/tennis/player1"}},
+		{"sport", []string{"#", "sport/#"}},
+		{"sport/", []string{"#", "+/+", "sport/#"}},
+		{"news/today", []string{"#", "+/+", "news/#"}},
+		{"sport+", []string{"#", "sport+"}},
+		{"", []string{"#"}},
+	}
+	for _, test := range tests {
+		if keys := matchingPatterns(&subscriptions, test.topic, MQTTWildcards); !reflect.DeepEqual(keys, test.expected) {
+			t.Errorf("%q: expected %q, got %q", test.topic, test.expected, keys)
+		}
+	}
+}
+
+func TestMatchPatternsGlob(t *testing.T) {
+	trie := NewTree[int]()
+	for i, pattern := range []string{"*.go", "*_test.go", "[a-c]*", "[!a]*.txt", "?", "[x", "dir/*"} {
+		trie.Insert(pattern, i)
+	}
+
+	tests := []struct {
+		subject  string
+		expected []string
+	}{
+		{"a.go", []string{"*.go", "[a-c]*"}},
+		{"b_test.go", []string{"*.go", "*_test.go", "[a-c]*"}},
+		{"z.txt", []string{"[!a]*.txt"}},
+		{"z", []string{"?"}},
+		{"[x", []string{"[x"}},
+		{"dir/c", []string{"dir/*"}},
+	}
+	for _, test := range tests {
+		if keys := matchingPatterns(&trie, test.subject, GlobWildcards); !reflect.DeepEqual(keys, test.expected) {
+			t.Errorf("%q: expected %q, got %q", test.subject, test.expected, keys)
+		}
+	}
+}
+
+func TestMatchSkipsExpired(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[int](WithClock(clock))
+	trie.InsertWithExpiry("a/b", 1, time.Minute)
+	trie.Insert("a/c", 2)
+	clock.Advance(time.Hour)
+	if keys := matchedKeys(&trie, "a/+", MQTTWildcards); !reflect.DeepEqual(keys, []string{"a/c"}) {
+		t.Errorf("expected [a/c], got %q", keys)
+	}
+	if keys := matchingPatterns(&trie, "a/b", MQTTWildcards); keys != nil {
+		t.Errorf("expected no patterns, got %q", keys)
+	}
+}
+
+func TestMatchStops(t *testing.T) {
+	trie := NewTree[int]()
+	for i := 0; i < 10; i++ {
+		trie.Insert(fmt.Sprintf("k%d", i), i)
+	}
+	count := 0
+	trie.Match("k*", GlobWildcards, func(string, int) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 {
+		t.Errorf("expected the walk to stop after 3 keys, got %d", count)
+	}
+}
+
+// globMatch matches key against a glob pattern with path.Match, which agrees with GlobWildcards on patterns whose bracket
+// expressions cannot match the separator.
+func globMatch(pattern, key string) bool {
+	matched, err := path.Match(pattern, key)
+	return err == nil && matched
+}
+
+func TestMatchRandomGlobs(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	alphabet := "ab/."
+	randomString := func(n int) string {
+		var sb strings.Builder
+		for i := 0; i < n; i++ {
+			sb.WriteByte(alphabet[rng.Intn(len(alphabet))])
+		}
+		return sb.String()
+	}
+	trie := NewTree[int]()
+	keys := map[string]bool{}
+	for i := 0; i < 500; i++ {
+		key := randomString(rng.Intn(8))
+		trie.Insert(key, i)
+		keys[key] = true
+	}
+	patternBytes := []string{"a", "b", "/", ".", "*", "?", "[ab]", "[^a/]", "[a-b]"}
+	for i := 0; i < 300; i++ {
+		var sb strings.Builder
+		for j := rng.Intn(6); j >= 0; j-- {
+			sb.WriteString(patternBytes[rng.Intn(len(patternBytes))])
+		}
+		pattern := sb.String()
+
+		var expected []string
+		trie.Walk(func(key string, _ int) bool {
+			if globMatch(pattern, key) {
+				expected = append(expected, key)
+			}
+			return true
+		})
+		if got := matchedKeys(&trie, pattern, GlobWildcards); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Match(%q): expected %q, got %q", pattern, expected, got)
+		}
+
+		// Stored as a pattern, it matches the same keys
+		patterns := NewTree[int]()
+		patterns.Insert(pattern, 0)
+		for key := range keys {
+			matched := matchingPatterns(&patterns, key, GlobWildcards) != nil
+			if matched != globMatch(pattern, key) {
+				t.Fatalf("MatchPatterns(%q) with pattern %q: expected %v, got %v", key, pattern, !matched, matched)
+			}
+		}
+	}
+}
+
+func BenchmarkMatchMQTT(b *testing.B) {
+	trie := NewTree[int]()
+	for i := 0; i < 10000; i++ {
+		trie.Insert(fmt.Sprintf("devices/%d/sensors/%d", i%1000, i), i)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		trie.Match("devices/42/sensors/+", MQTTWildcards, func(string, int) bool {
+			return true
+		})
+	}
+}
+
+func BenchmarkMatchPatternsMQTT(b *testing.B) {
+	trie := NewTree[int]()
+	for i := 0; i < 10000; i++ {
+		trie.Insert(fmt.Sprintf("devices/%d/sensors/%d", i%1000, i), i)
+		trie.Insert(fmt.Sprintf("devices/%d/+/%d", i%1000, i), i)
+		trie.Insert(fmt.Sprintf("devices/%d/#", i), i)
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		trie.MatchPatterns("devices/42/sensors/42", MQTTWildcards, func(string, int) bool {
+			return true
+		})
+	}
+}
@@ -1,38 +1,278 @@
 package trie
 
 import (
+	"sync/atomic"
 	"time"
 )
 
-// valueWithExpiry represents a value stored in the Trie with an optional expiry time.
+// valueWithExpiry represents a value stored in the Trie with an optional expiry time. Entries are never modified once they are
+// stored, since snapshots may share them: changing the value or the expiry time of a key stores a new entry.
 type valueWithExpiry[T any] struct {
-	value  T
-	expiry *time.Time
+	value   T
+	expiry  *time.Time
+	sliding time.Duration // if positive, the expiry time is pushed back to this long after every lookup
 }
 
-// node represents a node in the Trie.
+// node represents a node in the Trie. The Trie is path-compressed: every node is reached from its parent through an edge
+// labelled with one or more bytes, so chains of nodes without values or branches are folded into a single node.
+//
+// Nodes are copy-on-write. Every node belongs to the generation that created it, and a write only modifies nodes of its own
+// generation in place: older nodes may be shared with snapshots and persistent versions, so they are copied first.
 type node[T any] struct {
-	children map[byte]*node[T]
+	gen      uint64      // generation the node belongs to
+	prefix   []byte      // label of the edge leading from the parent to this node, empty for the root
+	children children[T] // children keyed by the first byte of their prefix
 	isEnd    bool
 	value    *valueWithExpiry[T]
 }
 
-// newNode creates and returns a new node instance.
-func newNode[T any]() *node[T] {
-	return &node[T]{children: make(map[byte]*node[T])} // we could preallocate the map with 256 to reduce allocations, but it's not worth it
+// generations hands out the generations of nodes.
+var generations uint64
+
+// nextGen returns a generation no node belongs to yet.
+func nextGen() uint64 {
+	return atomic.AddUint64(&generations, 1)
+}
+
+// newNode creates and returns a new node instance of generation gen, reached through an edge labelled prefix.
+func newNode[T any](gen uint64, prefix []byte) *node[T] {
+	return &node[T]{gen: gen, prefix: prefix}
+}
+
+// writable returns n if it belongs to generation gen, and otherwise a copy of n that does.
+func (n *node[T]) writable(gen uint64) *node[T] {
+	if n.gen == gen {
+		return n
+	}
+	c := *n
+	c.gen = gen
+	c.children = n.children.clone()
+	return &c
+}
+
+// own returns a version of child that belongs to generation gen, filing it in n in place of child if it had to be copied.
+// n must belong to gen.
+func (n *node[T]) own(gen uint64, child *node[T]) *node[T] {
+	owned := child.writable(gen)
+	if owned != child {
+		n.setChild(owned)
+	}
+	return owned
 }
 
 // setValue sets the value and optional expiry time for a node, and marks the node as an end node.
 func (n *node[T]) setValue(value T, expiry *time.Time) {
+	n.setEntry(&valueWithExpiry[T]{value: value, expiry: expiry})
+}
+
+// setEntry stores entry in a node, and marks the node as an end node.
+func (n *node[T]) setEntry(entry *valueWithExpiry[T]) {
 	n.isEnd = true
-	n.value = &valueWithExpiry[T]{value: value, expiry: expiry}
+	n.value = entry
+}
+
+// child returns the child node whose prefix starts with the given byte, or nil if there is none.
+func (n *node[T]) child(b byte) *node[T] {
+	return n.children.get(b)
+}
+
+// setChild adds child to n, replacing the child whose prefix starts with the same byte.
+func (n *node[T]) setChild(child *node[T]) {
+	n.children.set(child.prefix[0], child)
+}
+
+// removeChild removes the child whose prefix starts with the given byte.
+func (n *node[T]) removeChild(b byte) {
+	n.children.remove(b)
+}
+
+// descend follows key from n and returns the node it ends on, or nil if key does not end exactly on a node.
+func (n *node[T]) descend(key string) *node[T] {
+	for len(key) > 0 {
+		child := n.child(key[0])
+		if child == nil || !hasPrefix(key, child.prefix) {
+			return nil
+		}
+		key = key[len(child.prefix):]
+		n = child
+	}
+	return n
+}
+
+// seek follows prefix from n and returns the highest node whose path starts with prefix, together with that path, which may
+// extend past prefix when prefix ends inside an edge. It returns a nil node if no path starts with prefix.
+func (n *node[T]) seek(prefix string) (*node[T], []byte) {
+	path := make([]byte, 0, len(prefix)+16)
+	for len(prefix) > 0 {
+		child := n.child(prefix[0])
+		if child == nil {
+			return nil, nil
+		}
+		common := commonPrefix(child.prefix, prefix)
+		if common < len(child.prefix) && common < len(prefix) {
+			return nil, nil
+		}
+		path = append(path, child.prefix...)
+		prefix = prefix[common:]
+		n = child
+	}
+	return n, path
+}
+
+// insert returns the node for key in the subtree rooted at n, creating it and splitting edges as needed in generation gen.
+// The changes are made to result, which is n itself or its copy if n belongs to another generation. It also returns the
+// number of nodes that were created.
+func (n *node[T]) insert(gen uint64, key string) (result, target *node[T], created int) {
+	result = n.writable(gen)
+	n = result
+	for len(key) > 0 {
+		child := n.child(key[0])
+		if child == nil {
+			child = newNode[T](gen, []byte(key))
+			n.setChild(child)
+			return result, child, created + 1
+		}
+		child = n.own(gen, child)
+		common := commonPrefix(child.prefix, key)
+		if common < len(child.prefix) {
+			// key leaves the edge to child part way, so the edge is split with a new node at the branching point
+			mid := newNode[T](gen, child.prefix[:common])
+			child.prefix = child.prefix[common:]
+			mid.setChild(child)
+			n.setChild(mid)
+			child = mid
+			created++
+		}
+		key = key[common:]
+		n = child
+	}
+	return result, n, created
+}
+
+// walk calls fn for every value that is not expired at now in the subtree rooted at n, in lexicographic byte order of the keys. key is the path leading to n and is
+// extended in place while descending, so fn must copy it if it needs to keep it. It returns false if fn stopped the walk.
+func (n *node[T]) walk(key []byte, now time.Time, fn func(key []byte, value T) bool) bool {
+	return n.walkEntries(key, now, func(key []byte, entry *valueWithExpiry[T]) bool {
+		return fn(key, entry.value)
+	})
 }
 
-// getValue returns the value of a node if it is an end node and not expired. It returns nil if the value is expired.
-func (n *node[T]) getValue() (val T, notStale bool) {
+// walkEntries is like walk, but calls fn with the stored entries so their expiry times can be inspected.
+func (n *node[T]) walkEntries(key []byte, now time.Time, fn func(key []byte, entry *valueWithExpiry[T]) bool) bool {
+	if n.isEnd && !n.value.expired(now) {
+		if !fn(key, n.value) {
+			return false
+		}
+	}
+	return n.forEachChild(func(_ byte, child *node[T]) bool {
+		return child.walkEntries(append(key, child.prefix...), now, fn)
+	})
+}
+
+// forEachChild calls fn for every child of n in ascending byte order, together with the first byte of its prefix. fn may
+// remove or replace the child it is called with. It returns false if fn stopped the iteration.
+func (n *node[T]) forEachChild(fn func(b byte, child *node[T]) bool) bool {
+	for child := n.children.next(0); child != nil; {
+		b := child.prefix[0]
+		if !fn(b, child) {
+			return false
+		}
+		child = n.children.next(int(b) + 1)
+	}
+	return true
+}
+
+// remove clears the value stored under key in the subtree rooted at n and prunes the nodes that are no longer needed on the
+// way back up. If entry is not nil, the value is only removed if it is still entry. The changes are made in generation gen to
+// result, which is n itself, its copy if n belongs to another generation, or n untouched if nothing was removed. It also returns
+// the removed value, or nil if nothing was removed, and the number of pruned nodes.
+func (n *node[T]) remove(gen uint64, key string, entry *valueWithExpiry[T]) (result *node[T], old *valueWithExpiry[T], pruned int) {
+	target := n.descend(key)
+	if target == nil || !target.isEnd || (entry != nil && target.value != entry) {
+		return n, nil, 0
+	}
+	result = n.writable(gen)
+	old, pruned = result.removePath(gen, key)
+	return result, old, pruned
+}
+
+// removePath clears the value stored under key, which must exist, and compacts the nodes on the path to it. n must belong to gen.
+func (n *node[T]) removePath(gen uint64, key string) (old *valueWithExpiry[T], pruned int) {
+	if len(key) == 0 {
+		old = n.value
+		n.isEnd = false
+		n.value = nil
+		return old, 0
+	}
+	child := n.own(gen, n.child(key[0]))
+	old, pruned = child.removePath(gen, key[len(child.prefix):])
+	return old, pruned + n.compact(gen, child)
+}
+
+// sweep removes the values in the subtree rooted at n that are expired at now and prunes the nodes that are no longer needed.
+// key is the path leading to n and is extended in place while descending. If fn is not nil, it is called with the key and
+// value of every removed entry. The changes are made in generation gen to result, which is n itself, its copy if n belongs to
+// another generation, or n untouched if nothing expired. It also returns the number of removed values and pruned nodes.
+func (n *node[T]) sweep(gen uint64, key []byte, now time.Time, fn func(key []byte, value *valueWithExpiry[T])) (result *node[T], removed, pruned int) {
+	result = n
+	if n.isEnd && n.value.expired(now) {
+		if fn != nil {
+			fn(key, n.value)
+		}
+		result = n.writable(gen)
+		result.isEnd = false
+		result.value = nil
+		removed++
+	}
+	n.forEachChild(func(_ byte, child *node[T]) bool {
+		swept, childRemoved, childPruned := child.sweep(gen, append(key, child.prefix...), now, fn)
+		if childRemoved == 0 {
+			return true
+		}
+		result = result.writable(gen)
+		result.setChild(swept)
+		removed += childRemoved
+		pruned += childPruned + result.compact(gen, swept)
+		return true
+	})
+	return result, removed, pruned
+}
+
+// compact drops child from n if it holds neither a value nor children, or merges it into its only child if it holds no value.
+// n must belong to gen. It returns the number of released nodes.
+func (n *node[T]) compact(gen uint64, child *node[T]) int {
+	if child.isEnd {
+		return 0
+	}
+	switch child.children.len() {
+	case 0:
+		n.removeChild(child.prefix[0])
+		return 1
+	case 1:
+		grandchild := child.children.next(0).writable(gen)
+		prefix := make([]byte, 0, len(child.prefix)+len(grandchild.prefix))
+		grandchild.prefix = append(append(prefix, child.prefix...), grandchild.prefix...)
+		n.setChild(grandchild)
+		return 1
+	}
+	return 0
+}
+
+// isEmpty reports whether n holds neither a value nor children, so it can be dropped from its parent.
+func (n *node[T]) isEmpty() bool {
+	return !n.isEnd && n.children.len() == 0
+}
+
+// expired reports whether the value has an expiry time that lies before now.
+func (v *valueWithExpiry[T]) expired(now time.Time) bool {
+	return v.expiry != nil && v.expiry.Before(now)
+}
+
+// getValue returns the value of a node if it is an end node and not expired at now. It returns nil if the value is expired.
+func (n *node[T]) getValue(now time.Time) (val T, notStale bool) {
 	if n.isEnd {
 		val := n.value
-		if val.expiry != nil && val.expiry.Before(time.Now()) {
+		if val.expired(now) {
 			return val.value, false
 		}
 		return val.value, true
@@ -40,3 +280,17 @@ func (n *node[T]) getValue() (val T, notStale bool) {
 	t := new(T)
 	return *t, false
 }
+
+// hasPrefix reports whether key starts with prefix.
+func hasPrefix(key string, prefix []byte) bool {
+	return len(key) >= len(prefix) && key[:len(prefix)] == string(prefix)
+}
+
+// commonPrefix returns the length of the longest common prefix of a and b.
+func commonPrefix(a []byte, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,91 @@
+package trie
+
+import (
+	"fmt"
+	"time"
+)
+
+// Option configures a Tree when it is created. Every option applies to some of the constructors only, and the others panic
+// when given it, so that an option cannot be silently ignored.
+type Option func(*options)
+
+// scope is the set of features an option configures, which decides the constructors that accept it.
+type scope uint8
+
+const (
+	// scopeClock options configure the clock of a Tree, PersistentTree or one of the types built on them.
+	scopeClock scope = 1 << iota
+	// scopeTree options configure the eviction and capacity of a Tree or one of the types built on it.
+	scopeTree
+	// scopeCodec options configure the serialization of a Tree or a DurableTree.
+	scopeCodec
+	// scopeShard options configure a ShardedTree.
+	scopeShard
+	// scopeSegment options configure a SegmentTree.
+	scopeSegment
+	// scopeDurable options configure a DurableTree.
+	scopeDurable
+)
+
+// given is an option that was applied, with the features it configures.
+type given struct {
+	name  string
+	scope scope
+}
+
+type options struct {
+	given []given
+
+	clock   Clock
+	onEvict any
+	codec   any
+
+	shardPrefix int
+	separator   byte
+
+	maxEntries int
+	maxBytes   int64
+	sizeOf     any
+	newPolicy  func() EvictionPolicy
+
+	syncPolicy       SyncPolicy
+	syncInterval     time.Duration
+	compactThreshold int64
+}
+
+// newOptions applies opts on top of the defaults.
+func newOptions(opts []Option) *options {
+	o := &options{
+		clock:            systemClock{},
+		shardPrefix:      1,
+		separator:        '/',
+		syncInterval:     time.Second,
+		compactThreshold: 64 << 20,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// set records that the option called name, which configures the features in s, was applied.
+func (o *options) set(name string, s scope) {
+	o.given = append(o.given, given{name: name, scope: s})
+}
+
+// check panics if an option that was applied configures none of the features supported by constructor.
+func (o *options) check(constructor string, supported scope) {
+	for _, g := range o.given {
+		if g.scope&supported == 0 {
+			panic(fmt.Sprintf("trie: %s does not support %s", constructor, g.name))
+		}
+	}
+}
+
+// WithClock makes the Tree read the current time from clock instead of time.Now.
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		o.set("WithClock", scopeClock)
+		o.clock = clock
+	}
+}
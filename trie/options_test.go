@@ -0,0 +1,45 @@
+package trie
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnsupportedOptions(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	tests := []struct {
+		name string
+		new  func()
+	}{
+		{"NewTree/WithShardPrefix", func() { NewTree[int](WithShardPrefix(4)) }},
+		{"NewConcurrentTree/WithSeparator", func() { NewConcurrentTree[int](WithSeparator('.')) }},
+		{"NewIPTree/WithSyncPolicy", func() { NewIPTree[int](WithSyncPolicy(SyncNever)) }},
+		{"NewShardedTree/WithCompactionThreshold", func() { NewShardedTree[int](WithCompactionThreshold(0)) }},
+		{"NewSegmentTree/WithClock", func() { NewSegmentTree[int](WithClock(clock)) }},
+		{"NewPersistentTree/WithMaxEntries", func() { NewPersistentTree[int](WithMaxEntries(1)) }},
+		{"NewRCUTree/WithCodec", func() { NewRCUTree[int](WithCodec[int](intCodec{})) }},
+		{"NewShardedTree/WithCodec", func() { NewShardedTree[int](WithCodec[int](intCodec{})) }},
+		{"NewIPTree/WithCodec", func() { NewIPTree[int](WithCodec[int](intCodec{})) }},
+		{"NewConcurrentIPTree/WithCodec", func() { NewConcurrentIPTree[int](WithCodec[int](intCodec{})) }},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected the constructor to panic", test.name)
+				}
+			}()
+			test.new()
+		}()
+	}
+
+	// supported options are accepted, including those of the underlying Tree
+	NewShardedTree[int](WithClock(clock), WithMaxEntries(1), WithShardPrefix(2))
+	NewSegmentTree[int](WithSeparator('.'))
+	NewRCUTree[int](WithClock(clock))
+	tree, err := OpenDurableTree[int](t.TempDir(), WithClock(clock), WithCodec[int](intCodec{}), WithSyncPolicy(SyncNever))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	tree.Close()
+}
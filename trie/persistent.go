@@ -0,0 +1,149 @@
+package trie
+
+import (
+	"time"
+)
+
+// PersistentTree is an immutable Trie. Insert, Remove and Sweep leave the version they are called on untouched and return a
+// new version that shares every unchanged node with it, so old versions stay valid and cost only the nodes that differ.
+// A PersistentTree can be read from any number of goroutines without locking. Expired values are hidden from lookups, but
+// are only reclaimed by Sweep.
+type PersistentTree[T any] struct {
+	root   *node[T]
+	nodes  int
+	values int
+	clock  Clock
+}
+
+// NewPersistentTree creates and returns a new, empty PersistentTree.
+func NewPersistentTree[T any](opts ...Option) *PersistentTree[T] {
+	o := newOptions(opts)
+	o.check("NewPersistentTree", scopeClock)
+	return newPersistentTree[T](o)
+}
+
+// newPersistentTree creates an empty PersistentTree configured by o.
+func newPersistentTree[T any](o *options) *PersistentTree[T] {
+	return &PersistentTree[T]{
+		root:  newNode[T](nextGen(), nil),
+		nodes: 1,
+		clock: o.clock,
+	}
+}
+
+// Snapshot returns a consistent, read-only view of the Trie as it is now. Taking a snapshot does not copy anything: the snapshot
+// shares its nodes with the Tree, and from then on writes to the Tree copy the nodes they modify instead of changing them in
+// place. The snapshot can therefore be read without locking while writers continue to use the Tree.
+func (t *Tree[T]) Snapshot() *PersistentTree[T] {
+	if t.syncSafe {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+	}
+	t.gen = nextGen()
+	return &PersistentTree[T]{root: t.root, nodes: t.nodes, values: t.values, clock: t.clock}
+}
+
+// Insert returns a new version of the Trie in which key holds value.
+func (p *PersistentTree[T]) Insert(key string, value T) *PersistentTree[T] {
+	return p.insert(key, value, nil)
+}
+
+// InsertWithExpiry returns a new version of the Trie in which key holds value until the expiry duration has passed.
+func (p *PersistentTree[T]) InsertWithExpiry(key string, value T, expiry time.Duration) *PersistentTree[T] {
+	expiryTime := p.clock.Now().Add(expiry)
+	return p.insert(key, value, &expiryTime)
+}
+
+// Remove returns a new version of the Trie without key. It returns p itself if key holds no value.
+func (p *PersistentTree[T]) Remove(key string) *PersistentTree[T] {
+	next, _ := p.remove(key)
+	return next
+}
+
+// Sweep returns a new version of the Trie without the values that have expired. It returns p itself if nothing has expired.
+func (p *PersistentTree[T]) Sweep() *PersistentTree[T] {
+	root, removed, pruned := p.root.sweep(nextGen(), make([]byte, 0, 64), p.clock.Now(), nil)
+	if removed == 0 {
+		return p
+	}
+	return &PersistentTree[T]{root: root, nodes: p.nodes - pruned, values: p.values - removed, clock: p.clock}
+}
+
+// Find retrieves the value associated with the given key. It returns false if the key does not exist or the value has expired.
+func (p *PersistentTree[T]) Find(key string) (value T, found bool) {
+	node := p.root.descend(key)
+	if node == nil {
+		return *new(T), false
+	}
+	value, found = node.getValue(p.clock.Now())
+	if !found {
+		return *new(T), false
+	}
+	return value, true
+}
+
+// Len returns the number of values stored in this version, including the ones that have expired but have not been swept.
+func (p *PersistentTree[T]) Len() int {
+	return p.values
+}
+
+// Walk calls fn for every non-expired entry, in lexicographic byte order of the keys. Walking stops as soon as fn returns false.
+func (p *PersistentTree[T]) Walk(fn func(key string, value T) bool) {
+	p.root.rangeOver("", "", p.clock.Now(), fn)
+}
+
+// Range calls fn for every non-expired entry whose key lies in the half-open range [from, to), in lexicographic byte order of the
+// keys. An empty to means the range has no upper bound. Walking stops as soon as fn returns false.
+func (p *PersistentTree[T]) Range(from, to string, fn func(key string, value T) bool) {
+	p.root.rangeOver(from, to, p.clock.Now(), fn)
+}
+
+// FindPrefix returns the non-expired entries whose keys start with prefix, in lexicographic byte order of the keys. If limit is
+// greater than zero, at most limit entries are returned.
+func (p *PersistentTree[T]) FindPrefix(prefix string, limit int) []Entry[T] {
+	var entries []Entry[T]
+	p.root.walkPrefix(prefix, p.clock.Now(), func(key []byte, value T) bool {
+		entries = append(entries, Entry[T]{Key: string(key), Value: value})
+		return limit <= 0 || len(entries) < limit
+	})
+	return entries
+}
+
+// LongestPrefix returns the longest non-expired key that is a prefix of key, together with its value. found is false if no
+// stored key is a prefix of key.
+func (p *PersistentTree[T]) LongestPrefix(key string) (prefix string, value T, found bool) {
+	p.root.prefixesOf(key, p.clock.Now(), func(length int, v T) bool {
+		prefix, value, found = key[:length], v, true
+		return true
+	})
+	return prefix, value, found
+}
+
+// insert returns a new version of the Trie in which key holds value with an optional expiry time.
+func (p *PersistentTree[T]) insert(key string, value T, expiry *time.Time) *PersistentTree[T] {
+	next, _ := p.insertEntry(key, value, expiry)
+	return next
+}
+
+// insertEntry returns a new version of the Trie in which key holds value with an optional expiry time, together with the
+// entry it replaced, or nil if key held no value.
+func (p *PersistentTree[T]) insertEntry(key string, value T, expiry *time.Time) (*PersistentTree[T], *valueWithExpiry[T]) {
+	root, target, created := p.root.insert(nextGen(), key)
+	old := target.value
+	values := p.values
+	if old == nil {
+		values++
+	}
+	target.setValue(value, expiry)
+	return &PersistentTree[T]{root: root, nodes: p.nodes + created, values: values, clock: p.clock}, old
+}
+
+// remove returns a new version of the Trie without key, together with the removed entry. It returns p itself and a nil entry if
+// key holds no value.
+func (p *PersistentTree[T]) remove(key string) (*PersistentTree[T], *valueWithExpiry[T]) {
+	root, old, pruned := p.root.remove(nextGen(), key, nil)
+	if old == nil {
+		return p, nil
+	}
+	return &PersistentTree[T]{root: root, nodes: p.nodes - pruned, values: p.values - 1, clock: p.clock}, old
+}
@@ -0,0 +1,258 @@
+package trie
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// persistentEntries returns every non-expired entry of p in key order.
+func persistentEntries[T any](p *PersistentTree[T]) []Entry[T] {
+	var entries []Entry[T]
+	p.Walk(func(key string, value T) bool {
+		entries = append(entries, Entry[T]{Key: key, Value: value})
+		return true
+	})
+	return entries
+}
+
+func TestPersistentTreeVersions(t *testing.T) {
+	v0 := NewPersistentTree[int]()
+	v1 := v0.Insert("apple", 1)
+	v2 := v1.Insert("app", 2)
+	v3 := v2.Insert("apple", 3)
+	v4 := v3.Remove("app")
+
+	tests := []struct {
+		version  *PersistentTree[int]
+		expected []Entry[int]
+	}{
+		{v0, nil},
+		{v1, []Entry[int]{{"apple", 1}}},
+		{v2, []Entry[int]{{"app", 2}, {"apple", 1}}},
+		{v3, []Entry[int]{{"app", 2}, {"apple", 3}}},
+		{v4, []Entry[int]{{"apple", 3}}},
+	}
+	for i, test := range tests {
+		if entries := persistentEntries(test.version); !reflect.DeepEqual(entries, test.expected) {
+			t.Errorf("version %d: expected entries=%v, got entries=%v", i, test.expected, entries)
+		}
+		if test.version.Len() != len(test.expected) {
+			t.Errorf("version %d: expected len=%d, got len=%d", i, len(test.expected), test.version.Len())
+		}
+	}
+
+	// Removing a missing key returns the same version
+	if v4.Remove("banana") != v4 {
+		t.Errorf("expected removing a missing key to return the same version")
+	}
+}
+
+func TestPersistentTreeSharesNodes(t *testing.T) {
+	v1 := NewPersistentTree[int]()
+	for i := 0; i < 100; i++ {
+		v1 = v1.Insert(fmt.Sprintf("a/%02d", i), i)
+		v1 = v1.Insert(fmt.Sprintf("b/%02d", i), i)
+	}
+	v2 := v1.Insert("a/00", -1)
+
+	// The branch under "b/" is untouched and shared, while the path to "a/00" is copied
+	if v1.root.child('b') != v2.root.child('b') {
+		t.Errorf("expected the b/ branch to be shared between versions")
+	}
+	if v1.root.child('a') == v2.root.child('a') {
+		t.Errorf("expected the a/ branch to be copied")
+	}
+	if value, _ := v1.Find("a/00"); value != 0 {
+		t.Errorf("expected the old version to keep value 0, got %d", value)
+	}
+	if value, _ := v2.Find("a/00"); value != -1 {
+		t.Errorf("expected the new version to hold value -1, got %d", value)
+	}
+}
+
+func TestPersistentTreeRandomVersions(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	versions := []*PersistentTree[int]{NewPersistentTree[int]()}
+	expected := []map[string]int{{}}
+
+	for i := 0; i < 2000; i++ {
+		// Branch off a random earlier version
+		base := rng.Intn(len(versions))
+		state := map[string]int{}
+		for key, value := range expected[base] {
+			state[key] = value
+		}
+		key := fmt.Sprint(rng.Intn(200))
+		var next *PersistentTree[int]
+		if rng.Intn(3) == 0 {
+			next = versions[base].Remove(key)
+			delete(state, key)
+		} else {
+			next = versions[base].Insert(key, i)
+			state[key] = i
+		}
+		versions = append(versions, next)
+		expected = append(expected, state)
+	}
+
+	for i, version := range versions {
+		if version.Len() != len(expected[i]) {
+			t.Fatalf("version %d: expected len=%d, got len=%d", i, len(expected[i]), version.Len())
+		}
+		for key, value := range expected[i] {
+			if found, ok := version.Find(key); !ok || found != value {
+				t.Fatalf("version %d: expected %q=%d, got %d (found=%v)", i, key, value, found, ok)
+			}
+		}
+		checkCompressed(t, version.root, true)
+		if nodes := countNodes(version.root); nodes != version.nodes {
+			t.Fatalf("version %d: expected nodes=%d, got nodes=%d", i, nodes, version.nodes)
+		}
+	}
+}
+
+func TestPersistentTreeExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	v1 := NewPersistentTree[string](WithClock(clock)).
+		Insert("permanent", "a").
+		InsertWithExpiry("temporary", "b", time.Minute)
+
+	clock.Advance(time.Hour)
+	if _, found := v1.Find("temporary"); found {
+		t.Errorf("expected temporary to be expired")
+	}
+	if v1.Len() != 2 {
+		t.Errorf("expected expired values to count until swept, got len=%d", v1.Len())
+	}
+
+	v2 := v1.Sweep()
+	if v2.Len() != 1 || v1.Len() != 2 {
+		t.Errorf("expected len=1 after sweeping and len=2 before, got %d and %d", v2.Len(), v1.Len())
+	}
+	if v2.Sweep() != v2 {
+		t.Errorf("expected sweeping without expired values to return the same version")
+	}
+}
+
+func TestPersistentTreeLookups(t *testing.T) {
+	p := NewPersistentTree[int]().
+		Insert("/api", 1).
+		Insert("/api/users", 2).
+		Insert("/static", 3)
+
+	if entries := p.FindPrefix("/api", 0); !reflect.DeepEqual(entries, []Entry[int]{{"/api", 1}, {"/api/users", 2}}) {
+		t.Errorf("unexpected prefix entries %v", entries)
+	}
+	if prefix, value, found := p.LongestPrefix("/api/users/42"); prefix != "/api/users" || value != 2 || !found {
+		t.Errorf("expected (/api/users, 2, true), got (%q, %d, %v)", prefix, value, found)
+	}
+	var ranged []string
+	p.Range("/api/", "/t", func(key string, _ int) bool {
+		ranged = append(ranged, key)
+		return true
+	})
+	if !reflect.DeepEqual(ranged, []string{"/api/users", "/static"}) {
+		t.Errorf("expected keys=[/api/users /static], got keys=%v", ranged)
+	}
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
+	trie.Insert("hello", "world")
+	trie.Insert("help", "me")
+	trie.InsertWithExpiry("temp", "data", time.Minute)
+
+	snapshot := trie.Snapshot()
+	expected := persistentEntries(snapshot)
+
+	// Every kind of write to the tree leaves the snapshot untouched
+	trie.Insert("hello", "universe")
+	trie.Insert("helium", "gas")
+	trie.Remove("help")
+	clock.Advance(30 * time.Second)
+	trie.Sweep()
+	clock.Advance(time.Hour)
+	trie.Sweep()
+
+	clock.Set(time.Unix(0, 0))
+	if entries := persistentEntries(snapshot); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected entries=%v, got entries=%v", expected, entries)
+	}
+	if snapshot.Len() != 3 {
+		t.Errorf("expected len=3, got len=%d", snapshot.Len())
+	}
+	expectedTree := []Entry[string]{{"helium", "gas"}, {"hello", "universe"}}
+	if entries := entriesOf(&trie); !reflect.DeepEqual(entries, expectedTree) {
+		t.Errorf("expected entries=%v, got entries=%v", expectedTree, entries)
+	}
+	if stats := trie.Stats(); stats.Nodes != countNodes(trie.root) || stats.Values != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestSnapshotConcurrentWithWriters(t *testing.T) {
+	trie := NewConcurrentTree[int]()
+	for i := 0; i < 1000; i++ {
+		trie.Insert(fmt.Sprint(i), i)
+	}
+	snapshot := trie.Snapshot()
+
+	done := make(chan bool)
+
+	// Concurrent writes to the tree
+	go func() {
+		for i := 0; i < 1000; i++ {
+			trie.Insert(fmt.Sprint(i), -i)
+			trie.Remove(fmt.Sprint((i + 500) % 1000))
+			if i%100 == 0 {
+				trie.Snapshot()
+			}
+		}
+		done <- true
+	}()
+
+	// Concurrent lock-free reads of the snapshot
+	go func() {
+		for i := 0; i < 1000; i++ {
+			if value, found := snapshot.Find(fmt.Sprint(i)); !found || value != i {
+				t.Errorf("expected found=true, value=%d, got found=%v, value=%v", i, found, value)
+			}
+		}
+		count := 0
+		snapshot.Walk(func(string, int) bool {
+			count++
+			return true
+		})
+		if count != 1000 {
+			t.Errorf("expected 1000 entries, got %d", count)
+		}
+		done <- true
+	}()
+
+	<-done
+	<-done
+}
+
+func BenchmarkPersistentInsert(b *testing.B) {
+	p := NewPersistentTree[string]()
+	for n := 0; n < b.N; n++ {
+		p = p.Insert(fmt.Sprint(n), "value")
+	}
+}
+
+func BenchmarkInsertAfterSnapshot(b *testing.B) {
+	trie := NewTree[string]()
+	for n := 0; n < 1000; n++ {
+		trie.Insert(fmt.Sprint(n), "value")
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		trie.Snapshot()
+		trie.Insert(fmt.Sprint(n%1000), "newvalue")
+	}
+}
@@ -0,0 +1,167 @@
+package trie
+
+import (
+	"container/heap"
+	"container/list"
+	"sync"
+)
+
+// EvictionPolicy chooses the values a capacity-bounded Tree evicts once it holds more than its capacity. The Tree tells the
+// policy about every key it stores, finds and removes, and asks it for a victim while it is over capacity.
+//
+// Accessed is called by concurrent readers, so implementations must be safe for concurrent use. Keys the policy does not
+// know about must be ignored by Accessed and Removed.
+type EvictionPolicy interface {
+	// Inserted records that key was stored in the Tree.
+	Inserted(key string)
+	// Accessed records that the value of key was found or overwritten.
+	Accessed(key string)
+	// Removed records that key left the Tree.
+	Removed(key string)
+	// Victim returns the key to evict next, without forgetting it. It returns false if the policy tracks no keys.
+	Victim() (key string, ok bool)
+}
+
+// lruPolicy evicts the least recently used key.
+type lruPolicy struct {
+	mu    sync.Mutex
+	order *list.List // keys from the most to the least recently used
+	keys  map[string]*list.Element
+}
+
+// NewLRUPolicy returns an EvictionPolicy that evicts the least recently inserted or accessed key.
+func NewLRUPolicy() EvictionPolicy {
+	return &lruPolicy{order: list.New(), keys: make(map[string]*list.Element)}
+}
+
+func (p *lruPolicy) Inserted(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if elem, ok := p.keys[key]; ok {
+		p.order.MoveToFront(elem)
+		return
+	}
+	p.keys[key] = p.order.PushFront(key)
+}
+
+func (p *lruPolicy) Accessed(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if elem, ok := p.keys[key]; ok {
+		p.order.MoveToFront(elem)
+	}
+}
+
+func (p *lruPolicy) Removed(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if elem, ok := p.keys[key]; ok {
+		p.order.Remove(elem)
+		delete(p.keys, key)
+	}
+}
+
+func (p *lruPolicy) Victim() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if elem := p.order.Back(); elem != nil {
+		return elem.Value.(string), true
+	}
+	return "", false
+}
+
+// lfuPolicy evicts the least frequently used key, and among those the least recently used one.
+type lfuPolicy struct {
+	mu    sync.Mutex
+	heap  lfuHeap
+	keys  map[string]*lfuEntry
+	clock uint64 // counts uses, to break ties between equally frequent keys
+}
+
+// lfuEntry is a key tracked by an lfuPolicy.
+type lfuEntry struct {
+	key   string
+	uses  uint64
+	last  uint64 // value of the policy clock when the key was last used
+	index int    // position in the heap
+}
+
+// NewLFUPolicy returns an EvictionPolicy that evicts the least frequently accessed key, breaking ties by evicting the least
+// recently used one.
+func NewLFUPolicy() EvictionPolicy {
+	return &lfuPolicy{keys: make(map[string]*lfuEntry)}
+}
+
+func (p *lfuPolicy) Inserted(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.keys[key]; ok {
+		p.use(entry)
+		return
+	}
+	p.clock++
+	entry := &lfuEntry{key: key, uses: 1, last: p.clock}
+	p.keys[key] = entry
+	heap.Push(&p.heap, entry)
+}
+
+func (p *lfuPolicy) Accessed(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.keys[key]; ok {
+		p.use(entry)
+	}
+}
+
+func (p *lfuPolicy) Removed(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if entry, ok := p.keys[key]; ok {
+		heap.Remove(&p.heap, entry.index)
+		delete(p.keys, key)
+	}
+}
+
+func (p *lfuPolicy) Victim() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.heap) == 0 {
+		return "", false
+	}
+	return p.heap[0].key, true
+}
+
+// use counts a use of entry. It must be called while holding the policy's lock.
+func (p *lfuPolicy) use(entry *lfuEntry) {
+	p.clock++
+	entry.uses++
+	entry.last = p.clock
+	heap.Fix(&p.heap, entry.index)
+}
+
+// lfuHeap is a min-heap of keys ordered by their number of uses and then by their last use.
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].uses != h[j].uses {
+		return h[i].uses < h[j].uses
+	}
+	return h[i].last < h[j].last
+}
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *lfuHeap) Push(x any) {
+	entry := x.(*lfuEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+func (h *lfuHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
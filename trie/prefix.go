@@ -0,0 +1,121 @@
+package trie
+
+import (
+	"time"
+)
+
+// Entry is a key-value pair stored in the Trie.
+type Entry[T any] struct {
+	Key   string
+	Value T
+}
+
+// FindPrefix returns the non-expired entries whose keys start with prefix, in lexicographic byte order of the keys. If limit is greater than zero, at most limit entries are returned.
+func (t *Tree[T]) FindPrefix(prefix string, limit int) []Entry[T] {
+	var entries []Entry[T]
+	t.walkPrefix(prefix, func(key []byte, value T) bool {
+		entries = append(entries, Entry[T]{Key: string(key), Value: value})
+		return limit <= 0 || len(entries) < limit
+	})
+	return entries
+}
+
+// KeysWithPrefix returns the keys of the non-expired entries that start with prefix, in lexicographic byte order. If limit is greater than zero, at most limit keys are returned.
+func (t *Tree[T]) KeysWithPrefix(prefix string, limit int) []string {
+	var keys []string
+	t.walkPrefix(prefix, func(key []byte, _ T) bool {
+		keys = append(keys, string(key))
+		return limit <= 0 || len(keys) < limit
+	})
+	return keys
+}
+
+// ValuesWithPrefix returns the values of the non-expired entries whose keys start with prefix. If limit is greater than zero, at most limit values are returned.
+func (t *Tree[T]) ValuesWithPrefix(prefix string, limit int) []T {
+	var values []T
+	t.walkPrefix(prefix, func(_ []byte, value T) bool {
+		values = append(values, value)
+		return limit <= 0 || len(values) < limit
+	})
+	return values
+}
+
+// HasPrefix reports whether at least one non-expired entry has a key starting with prefix.
+func (t *Tree[T]) HasPrefix(prefix string) bool {
+	found := false
+	t.walkPrefix(prefix, func(_ []byte, _ T) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// walkPrefix calls fn for every non-expired entry under prefix while holding the read lock. Walking stops when fn returns false.
+func (t *Tree[T]) walkPrefix(prefix string, fn func(key []byte, value T) bool) {
+	if t.syncSafe {
+		t.lock.RLock()
+		defer t.lock.RUnlock()
+	}
+	t.root.walkPrefix(prefix, t.now(), fn)
+}
+
+// walkPrefix calls fn for every value that is not expired at now in the subtree rooted at n whose key starts with prefix, in
+// lexicographic byte order. Walking stops when fn returns false.
+func (n *node[T]) walkPrefix(prefix string, now time.Time, fn func(key []byte, value T) bool) {
+	node, path := n.seek(prefix)
+	if node == nil {
+		return
+	}
+	node.walk(path, now, fn)
+}
+
+// LongestPrefix returns the longest non-expired key that is a prefix of key, together with its value. found is false if no
+// stored key is a prefix of key.
+func (t *Tree[T]) LongestPrefix(key string) (prefix string, value T, found bool) {
+	t.prefixesOf(key, func(length int, v T) bool {
+		prefix, value, found = key[:length], v, true
+		return true
+	})
+	return prefix, value, found
+}
+
+// AllPrefixesOf returns the non-expired entries whose keys are prefixes of key, from the shortest to the longest.
+func (t *Tree[T]) AllPrefixesOf(key string) []Entry[T] {
+	var entries []Entry[T]
+	t.prefixesOf(key, func(length int, value T) bool {
+		entries = append(entries, Entry[T]{Key: key[:length], Value: value})
+		return true
+	})
+	return entries
+}
+
+// prefixesOf calls fn with the length and value of every non-expired key that is a prefix of key, from the shortest to the
+// longest, while holding the read lock. It stops when fn returns false.
+func (t *Tree[T]) prefixesOf(key string, fn func(length int, value T) bool) {
+	if t.syncSafe {
+		t.lock.RLock()
+		defer t.lock.RUnlock()
+	}
+	t.root.prefixesOf(key, t.now(), fn)
+}
+
+// prefixesOf calls fn with the length and value of every key in the subtree rooted at n that is a prefix of key and whose value
+// is not expired at now, from the shortest to the longest. It stops when fn returns false.
+func (n *node[T]) prefixesOf(key string, now time.Time, fn func(length int, value T) bool) {
+	length := 0
+	for {
+		if value, ok := n.getValue(now); ok {
+			if !fn(length, value) {
+				return
+			}
+		}
+		if length == len(key) {
+			return
+		}
+		child := n.child(key[length])
+		if child == nil || !hasPrefix(key[length:], child.prefix) {
+			return
+		}
+		n, length = child, length+len(child.prefix)
+	}
+}
@@ -0,0 +1,235 @@
+package trie
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFindPrefix(t *testing.T) {
+	trie := NewTree[int]()
+	trie.Insert("app", 1)
+	trie.Insert("apple", 2)
+	trie.Insert("application", 3)
+	trie.Insert("banana", 4)
+
+	entries := trie.FindPrefix("app", 0)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	expected := []Entry[int]{{"app", 1}, {"apple", 2}, {"application", 3}}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected entries=%v, got entries=%v", expected, entries)
+	}
+
+	// An empty prefix matches everything
+	if entries := trie.FindPrefix("", 0); len(entries) != 4 {
+		t.Errorf("expected 4 entries, got %d", len(entries))
+	}
+
+	// A prefix ending inside an edge matches the subtree below that edge
+	if keys := trie.KeysWithPrefix("ap", 0); len(keys) != 3 {
+		t.Errorf("expected 3 keys, got %v", keys)
+	}
+	if keys := trie.KeysWithPrefix("appl", 0); !reflect.DeepEqual(keys, []string{"apple", "application"}) {
+		t.Errorf("expected keys=[apple application], got keys=%v", keys)
+	}
+	if keys := trie.KeysWithPrefix("applz", 0); len(keys) != 0 {
+		t.Errorf("expected no keys, got %v", keys)
+	}
+
+	// A prefix that does not exist matches nothing
+	if entries := trie.FindPrefix("cherry", 0); len(entries) != 0 {
+		t.Errorf("expected no entries, got %v", entries)
+	}
+}
+
+func TestFindPrefixLimit(t *testing.T) {
+	trie := NewTree[int]()
+	for i := 0; i < 100; i++ {
+		trie.Insert(fmt.Sprintf("key-%d", i), i)
+	}
+
+	if keys := trie.KeysWithPrefix("key-", 10); len(keys) != 10 {
+		t.Errorf("expected 10 keys, got %d", len(keys))
+	}
+	if values := trie.ValuesWithPrefix("key-", 5); len(values) != 5 {
+		t.Errorf("expected 5 values, got %d", len(values))
+	}
+	if keys := trie.KeysWithPrefix("key-", 0); len(keys) != 100 {
+		t.Errorf("expected 100 keys, got %d", len(keys))
+	}
+}
+
+func TestFindPrefixSkipsExpired(t *testing.T) {
+	trie := NewTree[string]()
+	trie.Insert("user/1", "alice")
+	trie.InsertWithExpiry("user/2", "bob", -time.Second)
+	trie.InsertWithExpiry("user/3", "carol", time.Hour)
+
+	keys := trie.KeysWithPrefix("user/", 0)
+	sort.Strings(keys)
+	expected := []string{"user/1", "user/3"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected keys=%v, got keys=%v", expected, keys)
+	}
+
+	// A subtree holding only expired entries has no prefix matches
+	trie.InsertWithExpiry("group/1", "admins", -time.Second)
+	if trie.HasPrefix("group/") {
+		t.Errorf("expected HasPrefix=false for expired subtree")
+	}
+	if !trie.HasPrefix("user/") {
+		t.Errorf("expected HasPrefix=true")
+	}
+}
+
+func TestFindPrefixRemoved(t *testing.T) {
+	trie := NewTree[string]()
+	trie.Insert("hello", "world")
+	trie.Insert("help", "me")
+	trie.Remove("hello")
+
+	keys := trie.KeysWithPrefix("hel", 0)
+	if !reflect.DeepEqual(keys, []string{"help"}) {
+		t.Errorf("expected keys=[help], got keys=%v", keys)
+	}
+}
+
+func TestFindPrefixConcurrency(t *testing.T) {
+	trie := NewConcurrentTree[int]()
+
+	done := make(chan bool)
+
+	// Concurrent inserts
+	go func() {
+		for i := 0; i < 1000; i++ {
+			trie.Insert(fmt.Sprint(i), i)
+		}
+		done <- true
+	}()
+
+	// Concurrent prefix searches
+	go func() {
+		for i := 0; i < 1000; i++ {
+			trie.FindPrefix(fmt.Sprint(i%10), 10)
+		}
+		done <- true
+	}()
+
+	<-done
+	<-done
+
+	if keys := trie.KeysWithPrefix("", 0); len(keys) != 1000 {
+		t.Errorf("expected 1000 keys, got %d", len(keys))
+	}
+}
+
+func BenchmarkFindPrefix(b *testing.B) {
+	trie := NewTree[string]()
+	for n := 0; n < 10000; n++ {
+		trie.Insert(fmt.Sprint(n), "value")
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		trie.FindPrefix(fmt.Sprint(n%100), 10)
+	}
+}
+
+func TestLongestPrefix(t *testing.T) {
+	trie := NewTree[string]()
+	trie.Insert("/", "root")
+	trie.Insert("/api", "api")
+	trie.Insert("/api/v1", "v1")
+	trie.Insert("/api/v1/users", "users")
+	trie.Insert("/static", "static")
+
+	tests := []struct {
+		key    string
+		prefix string
+		value  string
+		found  bool
+	}{
+		{"/api/v1/users/42", "/api/v1/users", "users", true},
+		{"/api/v1/user", "/api/v1", "v1", true},
+		{"/api/v2", "/api", "api", true},
+		{"/api", "/api", "api", true},
+		{"/ap", "/", "root", true},
+		{"/static/css/site.css", "/static", "static", true},
+		{"", "", "", false},
+		{"api", "", "", false},
+	}
+	for _, test := range tests {
+		prefix, value, found := trie.LongestPrefix(test.key)
+		if prefix != test.prefix || value != test.value || found != test.found {
+			t.Errorf("LongestPrefix(%q): expected (%q, %q, %v), got (%q, %q, %v)", test.key, test.prefix, test.value, test.found, prefix, value, found)
+		}
+	}
+}
+
+func TestLongestPrefixEmptyKey(t *testing.T) {
+	trie := NewTree[string]()
+	trie.Insert("", "default")
+	trie.Insert("44", "uk")
+
+	prefix, value, found := trie.LongestPrefix("4420")
+	if prefix != "44" || value != "uk" || !found {
+		t.Errorf("expected (44, uk, true), got (%q, %q, %v)", prefix, value, found)
+	}
+	prefix, value, found = trie.LongestPrefix("1212")
+	if prefix != "" || value != "default" || !found {
+		t.Errorf("expected ('', default, true), got (%q, %q, %v)", prefix, value, found)
+	}
+}
+
+func TestLongestPrefixSkipsExpired(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
+	trie.Insert("com.", "tld")
+	trie.InsertWithExpiry("com.example.", "example", time.Minute)
+	trie.InsertWithExpiry("com.example.www.", "www", time.Hour)
+
+	prefix, value, _ := trie.LongestPrefix("com.example.www.")
+	if prefix != "com.example.www." || value != "www" {
+		t.Errorf("expected (com.example.www., www), got (%q, %q)", prefix, value)
+	}
+
+	clock.Advance(2 * time.Hour)
+	prefix, value, found := trie.LongestPrefix("com.example.www.")
+	if prefix != "com." || value != "tld" || !found {
+		t.Errorf("expected (com., tld, true), got (%q, %q, %v)", prefix, value, found)
+	}
+}
+
+func TestAllPrefixesOf(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[int](WithClock(clock))
+	trie.Insert("1", 1)
+	trie.Insert("12", 12)
+	trie.InsertWithExpiry("123", 123, time.Second)
+	trie.Insert("1234", 1234)
+	trie.Insert("13", 13)
+	clock.Advance(time.Minute)
+
+	entries := trie.AllPrefixesOf("12345")
+	expected := []Entry[int]{{"1", 1}, {"12", 12}, {"1234", 1234}}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected entries=%v, got entries=%v", expected, entries)
+	}
+	if entries := trie.AllPrefixesOf("2"); len(entries) != 0 {
+		t.Errorf("expected no entries, got %v", entries)
+	}
+}
+
+func BenchmarkLongestPrefix(b *testing.B) {
+	trie := NewTree[int]()
+	for n := 0; n < 10000; n++ {
+		trie.Insert(fmt.Sprint(n), n)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		trie.LongestPrefix(fmt.Sprint(n))
+	}
+}
@@ -0,0 +1,99 @@
+package trie
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RCUTree is a thread-safe Trie whose readers never lock. It publishes its contents as a PersistentTree behind an atomic
+// pointer: readers load the current version and search it without synchronization, while writers take a mutex, build the
+// next version by copying only the nodes on the path they change, and swap it in (read-copy-update).
+//
+// Reads scale with the number of goroutines and never wait for writers. Writes are still serialized and allocate the copied
+// path, so RCUTree suits read-mostly workloads; spread write-heavy workloads over independent trees instead.
+// Expired values are hidden from lookups and reclaimed by Sweep.
+type RCUTree[T any] struct {
+	current atomic.Value // *PersistentTree[T]
+	mu      sync.Mutex   // serializes writers
+}
+
+// NewRCUTree creates and returns a new RCUTree instance.
+func NewRCUTree[T any](opts ...Option) *RCUTree[T] {
+	o := newOptions(opts)
+	o.check("NewRCUTree", scopeClock)
+	t := &RCUTree[T]{}
+	t.current.Store(newPersistentTree[T](o))
+	return t
+}
+
+// Insert adds a key-value pair to the Trie. It returns the old value (if any) and a boolean indicating if a value was replaced.
+func (t *RCUTree[T]) Insert(key string, value T) (oldValue T, replaced bool) {
+	return t.insert(key, value, nil)
+}
+
+// InsertWithExpiry adds a key-value pair to the Trie with an expiry duration. It returns the old value (if any) and a boolean indicating if a value was replaced.
+func (t *RCUTree[T]) InsertWithExpiry(key string, value T, expiry time.Duration) (oldValue T, replaced bool) {
+	expiryTime := t.load().clock.Now().Add(expiry)
+	return t.insert(key, value, &expiryTime)
+}
+
+// Find retrieves the value associated with the given key. It returns false if the key does not exist or the value has expired.
+func (t *RCUTree[T]) Find(key string) (value T, found bool) {
+	return t.load().Find(key)
+}
+
+// Remove deletes the key-value pair from the Trie. It returns the old value (if any) and a boolean indicating if a value was removed.
+func (t *RCUTree[T]) Remove(key string) (oldValue T, removed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	next, old := t.load().remove(key)
+	if old == nil {
+		return *new(T), false
+	}
+	t.current.Store(next)
+	return old.value, true
+}
+
+// Sweep removes every expired entry from the Trie. It returns the number of removed entries.
+func (t *RCUTree[T]) Sweep() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	current := t.load()
+	next := current.Sweep()
+	t.current.Store(next)
+	return current.values - next.values
+}
+
+// Len returns the number of values stored in the Trie, including the ones that have expired but have not been swept.
+func (t *RCUTree[T]) Len() int {
+	return t.load().Len()
+}
+
+// Walk calls fn for every non-expired entry, in lexicographic byte order of the keys. Walking stops as soon as fn returns false.
+// The walk sees the Trie as it was when Walk was called, and fn may modify the Trie.
+func (t *RCUTree[T]) Walk(fn func(key string, value T) bool) {
+	t.load().Walk(fn)
+}
+
+// Snapshot returns the current version of the Trie. It is consistent and stays unchanged while writers continue.
+func (t *RCUTree[T]) Snapshot() *PersistentTree[T] {
+	return t.load()
+}
+
+// insert publishes a version of the Trie in which key holds value with an optional expiry time.
+func (t *RCUTree[T]) insert(key string, value T, expiry *time.Time) (oldValue T, replaced bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	next, old := t.load().insertEntry(key, value, expiry)
+	t.current.Store(next)
+	if old == nil {
+		return *new(T), false
+	}
+	return old.value, true
+}
+
+// load returns the current version of the Trie.
+func (t *RCUTree[T]) load() *PersistentTree[T] {
+	return t.current.Load().(*PersistentTree[T])
+}
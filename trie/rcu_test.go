@@ -0,0 +1,186 @@
+package trie
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRCUTreeInsertFindRemove(t *testing.T) {
+	trie := NewRCUTree[string]()
+
+	oldValue, replaced := trie.Insert("hello", "world")
+	if replaced || oldValue != "" {
+		t.Errorf("expected replaced=false, oldValue='', got replaced=%v, oldValue=%v", replaced, oldValue)
+	}
+	oldValue, replaced = trie.Insert("hello", "universe")
+	if !replaced || oldValue != "world" {
+		t.Errorf("expected replaced=true, oldValue='world', got replaced=%v, oldValue=%v", replaced, oldValue)
+	}
+	value, found := trie.Find("hello")
+	if !found || value != "universe" {
+		t.Errorf("expected found=true, value='universe', got found=%v, value=%v", found, value)
+	}
+
+	oldValue, removed := trie.Remove("hello")
+	if !removed || oldValue != "universe" {
+		t.Errorf("expected removed=true, oldValue='universe', got removed=%v, oldValue=%v", removed, oldValue)
+	}
+	oldValue, removed = trie.Remove("hello")
+	if removed || oldValue != "" {
+		t.Errorf("expected removed=false, oldValue='', got removed=%v, oldValue=%v", removed, oldValue)
+	}
+	if trie.Len() != 0 {
+		t.Errorf("expected len=0, got len=%d", trie.Len())
+	}
+}
+
+func TestRCUTreeExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewRCUTree[string](WithClock(clock))
+	trie.Insert("permanent", "a")
+	trie.InsertWithExpiry("temporary", "b", time.Minute)
+
+	clock.Advance(time.Hour)
+	if _, found := trie.Find("temporary"); found {
+		t.Errorf("expected temporary to be expired")
+	}
+	if removed := trie.Sweep(); removed != 1 {
+		t.Errorf("expected removed=1, got removed=%d", removed)
+	}
+	if trie.Len() != 1 {
+		t.Errorf("expected len=1, got len=%d", trie.Len())
+	}
+}
+
+func TestRCUTreeSnapshot(t *testing.T) {
+	trie := NewRCUTree[int]()
+	trie.Insert("a", 1)
+	snapshot := trie.Snapshot()
+	trie.Insert("a", 2)
+	trie.Insert("b", 3)
+
+	if value, _ := snapshot.Find("a"); value != 1 {
+		t.Errorf("expected the snapshot to keep value 1, got %d", value)
+	}
+	if snapshot.Len() != 1 {
+		t.Errorf("expected the snapshot to hold 1 value, got %d", snapshot.Len())
+	}
+}
+
+func TestRCUTreeWalkCanWrite(t *testing.T) {
+	trie := NewRCUTree[int]()
+	for i := 0; i < 10; i++ {
+		trie.Insert(fmt.Sprint(i), i)
+	}
+
+	// The walk sees a fixed version, so removing while walking neither deadlocks nor skips entries
+	count := 0
+	trie.Walk(func(key string, _ int) bool {
+		trie.Remove(key)
+		count++
+		return true
+	})
+	if count != 10 || trie.Len() != 0 {
+		t.Errorf("expected to walk and remove 10 entries, walked %d and left %d", count, trie.Len())
+	}
+}
+
+func TestRCUTreeConcurrency(t *testing.T) {
+	trie := NewRCUTree[int]()
+	var wg sync.WaitGroup
+
+	// Concurrent writers on disjoint keys
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				trie.Insert(fmt.Sprintf("%d/%d", w, i), i)
+			}
+			for i := 0; i < 500; i += 2 {
+				trie.Remove(fmt.Sprintf("%d/%d", w, i))
+			}
+		}(w)
+	}
+
+	// Concurrent readers: every version they see is internally consistent
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				snapshot := trie.Snapshot()
+				count := 0
+				snapshot.Walk(func(string, int) bool {
+					count++
+					return true
+				})
+				if count != snapshot.Len() {
+					t.Errorf("expected %d entries, walked %d", snapshot.Len(), count)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	if trie.Len() != 1000 {
+		t.Errorf("expected len=1000, got len=%d", trie.Len())
+	}
+}
+
+func BenchmarkFindRCUParallel(b *testing.B) {
+	trie := NewRCUTree[string]()
+	for n := 0; n < 1000; n++ {
+		trie.Insert(fmt.Sprint(n), "value")
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		n := 0
+		for pb.Next() {
+			trie.Find(fmt.Sprint(n % 1000))
+			n++
+		}
+	})
+}
+
+func BenchmarkMixedOperationsRCUParallel(b *testing.B) {
+	trie := NewRCUTree[string]()
+	for n := 0; n < 1000; n++ {
+		trie.Insert(fmt.Sprint(n), "value")
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		n := 0
+		for pb.Next() {
+			trie.Insert(fmt.Sprint(n%1000), "newvalue")
+			trie.Find(fmt.Sprint(n % 1000))
+			trie.Remove(fmt.Sprint(n % 1000))
+			n++
+		}
+	})
+}
+
+func BenchmarkReadMostlyRCUParallel(b *testing.B) {
+	trie := NewRCUTree[string]()
+	for n := 0; n < 1000; n++ {
+		trie.Insert(fmt.Sprint(n), "value")
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		n := 0
+		for pb.Next() {
+			if n%10 == 0 {
+				trie.Insert(fmt.Sprint(n%1000), "newvalue")
+			} else {
+				trie.Find(fmt.Sprint(n % 1000))
+			}
+			n++
+		}
+	})
+}
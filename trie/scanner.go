@@ -0,0 +1,271 @@
+package trie
+
+import (
+	"io"
+	"sort"
+	"time"
+)
+
+// Occurrence is a stored key found in a text by a Scanner.
+type Occurrence[T any] struct {
+	Key   string
+	Value T
+	// Offset is the position of the first byte of the occurrence in the text.
+	Offset int
+}
+
+// Scanner finds every occurrence of a fixed set of keys in a text in a single pass, with an Aho-Corasick automaton. It is
+// compiled from a Tree by CompileScanner, and is immutable: it can be used from any number of goroutines at once, and does not
+// see later changes to the Tree.
+type Scanner[T any] struct {
+	root    [256]int32 // transitions from the root, which lead back to the root for bytes that start no key
+	states  []scanState
+	labels  []byte  // bytes of the transitions of all states, sorted within each state
+	targets []int32 // states the transitions lead to
+	keys    []string
+	values  []T
+}
+
+// scanState is a state of the automaton. Every state stands for a position in the Trie, that is a prefix of a key, and the
+// automaton is in the state of the longest prefix of a key that ends the text read so far.
+type scanState struct {
+	first, count int32 // transitions of the state, in labels and targets
+	fail         int32 // state of the longest proper suffix of this state's prefix that is a prefix of a key
+	output       int32 // key ending in this state, or -1
+	dict         int32 // nearest state along the fail links in which a key ends, or -1
+}
+
+// CompileScanner compiles the keys of the non-expired entries in the Trie into a Scanner. The empty key is left out, since it
+// would occur everywhere. The Tree is locked for reading while its keys are collected.
+func (t *Tree[T]) CompileScanner() *Scanner[T] {
+	s := &Scanner[T]{}
+	b := &scanBuilder[T]{s: s}
+	if t.syncSafe {
+		t.lock.RLock()
+	}
+	b.add(t.root, make([]byte, 0, 64), -1, t.now())
+	if t.syncSafe {
+		t.lock.RUnlock()
+	}
+	b.link()
+	return s
+}
+
+// Len returns the number of keys the Scanner looks for.
+func (s *Scanner[T]) Len() int {
+	return len(s.keys)
+}
+
+// Scan calls fn for every occurrence of a key in text, ordered by the position where they end and, among those ending at the
+// same position, from the longest to the shortest. Occurrences may overlap. Scanning stops as soon as fn returns false.
+func (s *Scanner[T]) Scan(text string, fn func(o Occurrence[T]) bool) {
+	state := int32(0)
+	for i := 0; i < len(text); i++ {
+		state = s.next(state, text[i])
+		if !s.report(state, i+1, fn) {
+			return
+		}
+	}
+}
+
+// ScanBytes is like Scan for a byte slice.
+func (s *Scanner[T]) ScanBytes(data []byte, fn func(o Occurrence[T]) bool) {
+	state := int32(0)
+	for i, c := range data {
+		state = s.next(state, c)
+		if !s.report(state, i+1, fn) {
+			return
+		}
+	}
+}
+
+// ScanReader is like Scan for the stream read from r, with offsets counted from the start of the stream. Occurrences spanning
+// several reads are found. It returns the first error returned by r other than io.EOF.
+func (s *Scanner[T]) ScanReader(r io.Reader, fn func(o Occurrence[T]) bool) error {
+	buf := make([]byte, 32*1024)
+	state := int32(0)
+	offset := 0
+	for {
+		n, err := r.Read(buf)
+		for i, c := range buf[:n] {
+			state = s.next(state, c)
+			if !s.report(state, offset+i+1, fn) {
+				return nil
+			}
+		}
+		offset += n
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// FindAll returns every occurrence of a key in text, in the order Scan reports them.
+func (s *Scanner[T]) FindAll(text string) []Occurrence[T] {
+	var occurrences []Occurrence[T]
+	s.Scan(text, func(o Occurrence[T]) bool {
+		occurrences = append(occurrences, o)
+		return true
+	})
+	return occurrences
+}
+
+// FindAllBytes is like FindAll for a byte slice.
+func (s *Scanner[T]) FindAllBytes(data []byte) []Occurrence[T] {
+	var occurrences []Occurrence[T]
+	s.ScanBytes(data, func(o Occurrence[T]) bool {
+		occurrences = append(occurrences, o)
+		return true
+	})
+	return occurrences
+}
+
+// next returns the state the automaton moves to from state on reading c.
+func (s *Scanner[T]) next(state int32, c byte) int32 {
+	for state != 0 {
+		if target := s.transition(state, c); target >= 0 {
+			return target
+		}
+		state = s.states[state].fail
+	}
+	return s.root[c]
+}
+
+// transition returns the state the transition of state on c leads to, or -1 if it has none.
+func (s *Scanner[T]) transition(state int32, c byte) int32 {
+	st := &s.states[state]
+	labels := s.labels[st.first : st.first+st.count]
+	if len(labels) <= 8 {
+		for i, label := range labels {
+			if label == c {
+				return s.targets[int(st.first)+i]
+			}
+		}
+		return -1
+	}
+	i := sort.Search(len(labels), func(i int) bool {
+		return labels[i] >= c
+	})
+	if i < len(labels) && labels[i] == c {
+		return s.targets[int(st.first)+i]
+	}
+	return -1
+}
+
+// report calls fn for every key ending in state, which was reached at position end of the text. It returns false if fn stopped
+// the scan.
+func (s *Scanner[T]) report(state int32, end int, fn func(o Occurrence[T]) bool) bool {
+	st := &s.states[state]
+	if st.output >= 0 && !s.emit(st.output, end, fn) {
+		return false
+	}
+	for d := st.dict; d >= 0; d = s.states[d].dict {
+		if !s.emit(s.states[d].output, end, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// emit calls fn with the occurrence of the given key ending at position end.
+func (s *Scanner[T]) emit(key int32, end int, fn func(o Occurrence[T]) bool) bool {
+	return fn(Occurrence[T]{Key: s.keys[key], Value: s.values[key], Offset: end - len(s.keys[key])})
+}
+
+// scanBuilder builds the automaton of a Scanner from the nodes of a Trie.
+type scanBuilder[T any] struct {
+	s           *Scanner[T]
+	transitions [][]scanTransition // transitions of every state, in ascending byte order
+}
+
+// scanTransition is a transition of the automaton under construction.
+type scanTransition struct {
+	label  byte
+	target int32
+}
+
+// newState adds a state without transitions or output and returns it.
+func (b *scanBuilder[T]) newState() int32 {
+	b.s.states = append(b.s.states, scanState{output: -1, dict: -1})
+	b.transitions = append(b.transitions, nil)
+	return int32(len(b.s.states) - 1)
+}
+
+// add adds the states for the subtree rooted at n, which is reached by key, and returns the state of n. Every byte of every edge
+// becomes a state. parent is the state that n's edge leaves from, or -1 for the root.
+func (b *scanBuilder[T]) add(n *node[T], key []byte, parent int32, now time.Time) int32 {
+	state := parent
+	if parent < 0 {
+		state = b.newState()
+	}
+	for i := range n.prefix {
+		next := b.newState()
+		b.transitions[state] = append(b.transitions[state], scanTransition{label: key[len(key)-len(n.prefix)+i], target: next})
+		state = next
+	}
+	if value, ok := n.getValue(now); ok && len(key) > 0 {
+		b.s.states[state].output = int32(len(b.s.keys))
+		b.s.keys = append(b.s.keys, string(key))
+		b.s.values = append(b.s.values, value)
+	}
+	n.forEachChild(func(_ byte, child *node[T]) bool {
+		b.add(child, append(key, child.prefix...), state, now)
+		return true
+	})
+	return state
+}
+
+// link computes the fail and dictionary links of the states breadth first, so the links of shorter prefixes are known when
+// they are needed, and lays out the transitions of the Scanner.
+func (b *scanBuilder[T]) link() {
+	s := b.s
+	for i := range s.root {
+		s.root[i] = 0
+	}
+	queue := make([]int32, 0, len(s.states))
+	for _, tr := range b.transitions[0] {
+		s.root[tr.label] = tr.target
+		s.states[tr.target].fail = 0
+		queue = append(queue, tr.target)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, tr := range b.transitions[state] {
+			queue = append(queue, tr.target)
+			fail := b.follow(s.states[state].fail, tr.label)
+			s.states[tr.target].fail = fail
+			if s.states[fail].output >= 0 {
+				s.states[tr.target].dict = fail
+			} else {
+				s.states[tr.target].dict = s.states[fail].dict
+			}
+		}
+	}
+	for state, transitions := range b.transitions {
+		s.states[state].first = int32(len(s.labels))
+		s.states[state].count = int32(len(transitions))
+		for _, tr := range transitions {
+			s.labels = append(s.labels, tr.label)
+			s.targets = append(s.targets, tr.target)
+		}
+	}
+	b.transitions = nil
+}
+
+// follow returns the state reached from state on c while the links are being computed, following the fail links of states
+// whose links are already known.
+func (b *scanBuilder[T]) follow(state int32, c byte) int32 {
+	for state != 0 {
+		for _, tr := range b.transitions[state] {
+			if tr.label == c {
+				return tr.target
+			}
+		}
+		state = b.s.states[state].fail
+	}
+	return b.s.root[c]
+}
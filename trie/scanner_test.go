@@ -0,0 +1,194 @@
+package trie
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// occurrencesOf finds the occurrences of the keys in text by brute force, in the order a Scanner reports them.
+func occurrencesOf(keys map[string]int, text string) []Occurrence[int] {
+	var occurrences []Occurrence[int]
+	for key, value := range keys {
+		if key == "" {
+			continue
+		}
+		for i := 0; i+len(key) <= len(text); i++ {
+			if text[i:i+len(key)] == key {
+				occurrences = append(occurrences, Occurrence[int]{Key: key, Value: value, Offset: i})
+			}
+		}
+	}
+	sort.Slice(occurrences, func(i, j int) bool {
+		a, b := occurrences[i], occurrences[j]
+		if a.Offset+len(a.Key) != b.Offset+len(b.Key) {
+			return a.Offset+len(a.Key) < b.Offset+len(b.Key)
+		}
+		return len(a.Key) > len(b.Key)
+	})
+	return occurrences
+}
+
+func TestScanner(t *testing.T) {
+	trie := NewTree[int]()
+	for i, key := range []string{"he", "she", "his", "hers"} {
+		trie.Insert(key, i)
+	}
+	scanner := trie.CompileScanner()
+	if scanner.Len() != 4 {
+		t.Errorf("expected 4 keys, got %d", scanner.Len())
+	}
+
+	expected := []Occurrence[int]{
+		{Key: "she", Value: 1, Offset: 1},
+		{Key: "he", Value: 0, Offset: 2},
+		{Key: "hers", Value: 3, Offset: 2},
+	}
+	if occurrences := scanner.FindAll("ushers"); !reflect.DeepEqual(occurrences, expected) {
+		t.Errorf("expected %v, got %v", expected, occurrences)
+	}
+	if occurrences := scanner.FindAllBytes([]byte("ushers")); !reflect.DeepEqual(occurrences, expected) {
+		t.Errorf("expected %v, got %v", expected, occurrences)
+	}
+	if occurrences := scanner.FindAll("nothing to see"); occurrences != nil {
+		t.Errorf("expected no occurrences, got %v", occurrences)
+	}
+}
+
+func TestScannerStopsEarly(t *testing.T) {
+	trie := NewTree[int]()
+	trie.Insert("a", 0)
+	scanner := trie.CompileScanner()
+
+	calls := 0
+	scanner.Scan("aaaa", func(o Occurrence[int]) bool {
+		calls++
+		return calls < 2
+	})
+	if calls != 2 {
+		t.Errorf("expected the scan to stop after 2 occurrences, got %d", calls)
+	}
+	calls = 0
+	err := scanner.ScanReader(strings.NewReader("aaaa"), func(o Occurrence[int]) bool {
+		calls++
+		return false
+	})
+	if err != nil || calls != 1 {
+		t.Errorf("expected the scan to stop after 1 occurrence without error, got %d and %v", calls, err)
+	}
+}
+
+func TestScannerSkipsExpiredAndEmptyKeys(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[int](WithClock(clock))
+	trie.Insert("", 0)
+	trie.Insert("foo", 1)
+	trie.InsertWithExpiry("bar", 2, time.Second)
+
+	// expired values are not compiled in
+	clock.Advance(2 * time.Second)
+	scanner := trie.CompileScanner()
+	if scanner.Len() != 1 {
+		t.Errorf("expected only foo to be compiled, got %d keys", scanner.Len())
+	}
+	expected := []Occurrence[int]{{Key: "foo", Value: 1, Offset: 3}}
+	if occurrences := scanner.FindAll("barfoo"); !reflect.DeepEqual(occurrences, expected) {
+		t.Errorf("expected %v, got %v", expected, occurrences)
+	}
+
+	// the Scanner does not see later changes
+	trie.Insert("bar", 3)
+	if occurrences := scanner.FindAll("barfoo"); !reflect.DeepEqual(occurrences, expected) {
+		t.Errorf("expected %v, got %v", expected, occurrences)
+	}
+}
+
+func TestScannerReader(t *testing.T) {
+	trie := NewTree[int]()
+	keys := map[string]int{"needle": 0, "needles": 1, "les": 2, "dle": 3}
+	for key, value := range keys {
+		trie.Insert(key, value)
+	}
+	scanner := trie.CompileScanner()
+	text := strings.Repeat("hay needles hay ", 5000)
+
+	// occurrences spanning reads are found, with offsets from the start of the stream
+	var occurrences []Occurrence[int]
+	err := scanner.ScanReader(iotest.OneByteReader(strings.NewReader(text)), func(o Occurrence[int]) bool {
+		occurrences = append(occurrences, o)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if expected := occurrencesOf(keys, text); !reflect.DeepEqual(occurrences, expected) {
+		t.Errorf("expected %d occurrences, got %d", len(expected), len(occurrences))
+	}
+
+	// errors other than io.EOF are returned
+	failure := errors.New("failure")
+	err = scanner.ScanReader(iotest.TimeoutReader(bytes.NewReader([]byte(text))), func(o Occurrence[int]) bool {
+		return true
+	})
+	if !errors.Is(err, iotest.ErrTimeout) {
+		t.Errorf("expected %v, got %v", iotest.ErrTimeout, err)
+	}
+	err = scanner.ScanReader(iotest.ErrReader(failure), func(o Occurrence[int]) bool {
+		return true
+	})
+	if err != failure {
+		t.Errorf("expected %v, got %v", failure, err)
+	}
+}
+
+func TestScannerRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomString := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = "abc"[rnd.Intn(3)]
+		}
+		return string(b)
+	}
+	for round := 0; round < 200; round++ {
+		trie := NewTree[int]()
+		keys := map[string]int{}
+		for i := 0; i < 1+rnd.Intn(20); i++ {
+			key := randomString(rnd.Intn(6))
+			keys[key] = i
+			trie.Insert(key, i)
+		}
+		scanner := trie.CompileScanner()
+		text := randomString(rnd.Intn(100))
+		if occurrences, expected := scanner.FindAll(text), occurrencesOf(keys, text); !reflect.DeepEqual(occurrences, expected) {
+			t.Fatalf("keys %v, text %q: expected %v, got %v", keys, text, expected, occurrences)
+		}
+	}
+}
+
+func BenchmarkScanner(b *testing.B) {
+	words := dictionary(10000)
+	scanner := words.CompileScanner()
+	line := []byte(strings.Repeat("GET /index.html HTTP/1.1 200 user=alice agent=curl ", 20))
+	b.SetBytes(int64(len(line)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scanner.ScanBytes(line, func(o Occurrence[int]) bool {
+			return true
+		})
+	}
+}
+
+func BenchmarkScannerCompile(b *testing.B) {
+	words := dictionary(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		words.CompileScanner()
+	}
+}
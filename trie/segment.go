@@ -0,0 +1,302 @@
+package trie
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidPattern is returned when inserting a malformed pattern into a SegmentTree.
+var ErrInvalidPattern = errors.New("trie: invalid pattern")
+
+// SegmentTree is a thread-safe Trie of patterns split into segments on a separator, such as the routes of an HTTP router.
+// A segment of a pattern is either static, and matches only itself, a named parameter such as ":id", which matches any
+// single non-empty segment, or a catch-all such as "*rest", which must be the last segment of the pattern and matches the
+// rest of the path, separators included, down to the empty string.
+//
+// When several patterns match a path, segments are matched from left to right, preferring a static segment over a parameter
+// and a parameter over a catch-all; if the preferred choice leads to no match further down, the next one is tried. So
+// "/users/new" wins over "/users/:id" for the path "/users/new", while "/users/:id/edit" still matches "/users/new/edit".
+type SegmentTree[T any] struct {
+	root      *segmentNode[T]
+	separator byte
+	lock      sync.RWMutex
+	size      int
+}
+
+// segmentNode is a node of a SegmentTree. It is reached by the segments of a pattern, with parameters and catch-alls
+// standing for any segment regardless of their names.
+type segmentNode[T any] struct {
+	static   map[string]*segmentNode[T]
+	param    *segmentNode[T]
+	catchAll *segmentRoute[T]
+	route    *segmentRoute[T]
+}
+
+// segmentRoute is a pattern stored in a SegmentTree. Patterns differing only in the names of their parameters share their
+// node, so the names are kept with the pattern.
+type segmentRoute[T any] struct {
+	pattern string
+	names   []string
+	value   T
+}
+
+// Param is a parameter captured by a pattern from a path.
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Params are the parameters captured by a pattern from a path, in the order they appear in the pattern.
+type Params []Param
+
+// Get returns the value of the parameter with the given name, and whether there is one.
+func (p Params) Get(name string) (string, bool) {
+	for _, param := range p {
+		if param.Name == name {
+			return param.Value, true
+		}
+	}
+	return "", false
+}
+
+// NewSegmentTree creates and returns a new SegmentTree instance, splitting patterns and paths on '/' unless configured
+// otherwise with WithSeparator.
+func NewSegmentTree[T any](opts ...Option) *SegmentTree[T] {
+	o := newOptions(opts)
+	o.check("NewSegmentTree", scopeSegment)
+	return &SegmentTree[T]{root: &segmentNode[T]{}, separator: o.separator}
+}
+
+// WithSeparator makes a SegmentTree split patterns and paths on separator instead of '/'.
+func WithSeparator(separator byte) Option {
+	return func(o *options) {
+		o.set("WithSeparator", scopeSegment)
+		o.separator = separator
+	}
+}
+
+// Insert adds a pattern and its value to the Trie. A pattern that differs from a stored one only in the names of its
+// parameters replaces it. It returns the old value (if any) and a boolean indicating if a value was replaced, or an error
+// wrapping ErrInvalidPattern if a parameter or catch-all has no name, a name is used twice, or a catch-all is not the last
+// segment.
+func (t *SegmentTree[T]) Insert(pattern string, value T) (oldValue T, replaced bool, err error) {
+	segments := strings.Split(pattern, string(t.separator))
+	var names []string
+	for i, segment := range segments {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		name := segment[1:]
+		switch {
+		case name == "":
+			return oldValue, false, fmt.Errorf("%w %q: segment %d has no name", ErrInvalidPattern, pattern, i)
+		case segment[0] == '*' && i != len(segments)-1:
+			return oldValue, false, fmt.Errorf("%w %q: catch-all %q is not the last segment", ErrInvalidPattern, pattern, segment)
+		}
+		for _, other := range names {
+			if other == name {
+				return oldValue, false, fmt.Errorf("%w %q: parameter %q is used twice", ErrInvalidPattern, pattern, name)
+			}
+		}
+		names = append(names, name)
+	}
+	route := &segmentRoute[T]{pattern: pattern, names: names, value: value}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	n := t.root
+	for i, segment := range segments {
+		switch {
+		case segment != "" && segment[0] == '*':
+			old := n.catchAll
+			n.catchAll = route
+			return t.replaced(old)
+		case segment != "" && segment[0] == ':':
+			if n.param == nil {
+				n.param = &segmentNode[T]{}
+			}
+			n = n.param
+		default:
+			child := n.static[segment]
+			if child == nil {
+				if n.static == nil {
+					n.static = make(map[string]*segmentNode[T])
+				}
+				child = &segmentNode[T]{}
+				n.static[segment] = child
+			}
+			n = child
+		}
+		if i == len(segments)-1 {
+			old := n.route
+			n.route = route
+			return t.replaced(old)
+		}
+	}
+	return oldValue, false, nil
+}
+
+// replaced updates the size of the Trie after a route replaced old, which is nil if the route is new, and returns the values
+// Insert reports.
+func (t *SegmentTree[T]) replaced(old *segmentRoute[T]) (oldValue T, replaced bool, err error) {
+	if old == nil {
+		t.size++
+		return oldValue, false, nil
+	}
+	return old.value, true, nil
+}
+
+// Lookup returns the value of the pattern matching path, following the precedence described on SegmentTree, together with the
+// parameters it captured. It returns false if no pattern matches.
+func (t *SegmentTree[T]) Lookup(path string) (value T, params Params, found bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	var captured [8]string
+	route, values := t.root.match(path, t.separator, captured[:0])
+	if route == nil {
+		return value, nil, false
+	}
+	if len(route.names) > 0 {
+		params = make(Params, len(route.names))
+		for i, name := range route.names {
+			params[i] = Param{Name: name, Value: values[i]}
+		}
+	}
+	return route.value, params, true
+}
+
+// match returns the route matching path, which starts at a segment below n, and the values of its parameters appended to
+// values. It returns a nil route if there is none.
+func (n *segmentNode[T]) match(path string, separator byte, values []string) (*segmentRoute[T], []string) {
+	segment, rest, last := path, "", true
+	if i := strings.IndexByte(path, separator); i >= 0 {
+		segment, rest, last = path[:i], path[i+1:], false
+	}
+	if child := n.static[segment]; child != nil {
+		if route, values := child.next(rest, last, separator, values); route != nil {
+			return route, values
+		}
+	}
+	if n.param != nil && segment != "" {
+		if route, values := n.param.next(rest, last, separator, append(values, segment)); route != nil {
+			return route, values
+		}
+	}
+	if n.catchAll != nil {
+		return n.catchAll, append(values, path)
+	}
+	return nil, values
+}
+
+// next returns the route matching the rest of a path at n, which was reached by a segment of the path. If that segment was the
+// last one, the route is the one ending at n.
+func (n *segmentNode[T]) next(rest string, last bool, separator byte, values []string) (*segmentRoute[T], []string) {
+	if last {
+		return n.route, values
+	}
+	return n.match(rest, separator, values)
+}
+
+// Remove deletes the given pattern, or one differing from it only in the names of its parameters, from the Trie. It returns the
+// old value (if any) and a boolean indicating if a value was removed.
+func (t *SegmentTree[T]) Remove(pattern string) (oldValue T, removed bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	route := t.root.remove(strings.Split(pattern, string(t.separator)))
+	if route == nil {
+		return oldValue, false
+	}
+	t.size--
+	return route.value, true
+}
+
+// remove removes the route reached from n by segments, pruning the nodes left empty, and returns it, or nil if there is none.
+func (n *segmentNode[T]) remove(segments []string) *segmentRoute[T] {
+	segment := segments[0]
+	var route *segmentRoute[T]
+	switch {
+	case segment != "" && segment[0] == '*':
+		if len(segments) == 1 {
+			route, n.catchAll = n.catchAll, nil
+		}
+	case segment != "" && segment[0] == ':':
+		if n.param != nil {
+			route = n.param.removeBelow(segments[1:])
+			if n.param.isEmpty() {
+				n.param = nil
+			}
+		}
+	default:
+		if child := n.static[segment]; child != nil {
+			route = child.removeBelow(segments[1:])
+			if child.isEmpty() {
+				delete(n.static, segment)
+			}
+		}
+	}
+	return route
+}
+
+// removeBelow removes the route reached from n by the remaining segments, or the route ending at n if there are none.
+func (n *segmentNode[T]) removeBelow(segments []string) *segmentRoute[T] {
+	if len(segments) == 0 {
+		route := n.route
+		n.route = nil
+		return route
+	}
+	return n.remove(segments)
+}
+
+// isEmpty reports whether n stores no route and has no children.
+func (n *segmentNode[T]) isEmpty() bool {
+	return n.route == nil && n.catchAll == nil && n.param == nil && len(n.static) == 0
+}
+
+// Len returns the number of patterns in the Trie.
+func (t *SegmentTree[T]) Len() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.size
+}
+
+// Walk calls fn for every pattern in the Trie and its value, in the order of precedence: below each node, patterns continuing
+// with a static segment come first, in lexicographic order of that segment, then those continuing with a parameter, then the
+// catch-all. Walking stops as soon as fn returns false. fn is called while the Trie is locked, so it must not modify it.
+func (t *SegmentTree[T]) Walk(fn func(pattern string, value T) bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	t.root.walk(fn)
+}
+
+// walk calls fn for every route below n, and reports whether walking should continue.
+func (n *segmentNode[T]) walk(fn func(pattern string, value T) bool) bool {
+	segments := make([]string, 0, len(n.static))
+	for segment := range n.static {
+		segments = append(segments, segment)
+	}
+	sort.Strings(segments)
+	for _, segment := range segments {
+		child := n.static[segment]
+		if !child.visit(fn) {
+			return false
+		}
+	}
+	if n.param != nil && !n.param.visit(fn) {
+		return false
+	}
+	if n.catchAll != nil && !fn(n.catchAll.pattern, n.catchAll.value) {
+		return false
+	}
+	return true
+}
+
+// visit calls fn for the route ending at n, if any, and then for the routes below it.
+func (n *segmentNode[T]) visit(fn func(pattern string, value T) bool) bool {
+	if n.route != nil && !fn(n.route.pattern, n.route.value) {
+		return false
+	}
+	return n.walk(fn)
+}
@@ -0,0 +1,155 @@
+package trie
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSegmentTreeLookup(t *testing.T) {
+	routes := NewSegmentTree[string]()
+	for _, pattern := range []string{
+		"/",
+		"/users",
+		"/users/new",
+		"/users/:id",
+		"/users/:id/edit",
+		"/users/:id/posts/:post",
+		"/files/*path",
+		"/*any",
+	} {
+		if _, _, err := routes.Insert(pattern, pattern); err != nil {
+			t.Fatalf("expected no error inserting %q, got %v", pattern, err)
+		}
+	}
+	if routes.Len() != 8 {
+		t.Errorf("expected 8 patterns, got %d", routes.Len())
+	}
+
+	tests := []struct {
+		path    string
+		pattern string
+		params  Params
+	}{
+		{"/", "/", nil},
+		{"/users", "/users", nil},
+		// static segments win over parameters
+		{"/users/new", "/users/new", nil},
+		{"/users/42", "/users/:id", Params{{"id", "42"}}},
+		// a static segment that leads nowhere falls back to the parameter
+		{"/users/new/edit", "/users/:id/edit", Params{{"id", "new"}}},
+		{"/users/42/posts/7", "/users/:id/posts/:post", Params{{"id", "42"}, {"post", "7"}}},
+		// catch-alls take the rest of the path, separators included
+		{"/files/a/b/c.txt", "/files/*path", Params{{"path", "a/b/c.txt"}}},
+		{"/files/", "/files/*path", Params{{"path", ""}}},
+		// parameters do not match empty segments
+		{"/users/", "/*any", Params{{"any", "users/"}}},
+		{"/users/42/delete", "/*any", Params{{"any", "users/42/delete"}}},
+		{"/files", "/*any", Params{{"any", "files"}}},
+	}
+	for _, test := range tests {
+		value, params, found := routes.Lookup(test.path)
+		if !found || value != test.pattern || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("%s: expected %s %v, got %s %v %v", test.path, test.pattern, test.params, value, params, found)
+		}
+	}
+
+	if _, _, found := routes.Lookup("users"); found {
+		t.Errorf("expected no match for a path without a leading separator")
+	}
+	if _, params, _ := routes.Lookup("/users/42/posts/7"); params != nil {
+		if post, ok := params.Get("post"); !ok || post != "7" {
+			t.Errorf("expected post 7, got %q %v", post, ok)
+		}
+		if _, ok := params.Get("missing"); ok {
+			t.Errorf("expected no missing parameter")
+		}
+	}
+}
+
+func TestSegmentTreeInsert(t *testing.T) {
+	routes := NewSegmentTree[int]()
+	routes.Insert("/users/:id", 1)
+
+	// a pattern differing only in parameter names replaces the stored one
+	old, replaced, err := routes.Insert("/users/:name", 2)
+	if err != nil || !replaced || old != 1 {
+		t.Errorf("expected to replace 1, got %d %v %v", old, replaced, err)
+	}
+	if _, params, _ := routes.Lookup("/users/bob"); !reflect.DeepEqual(params, Params{{"name", "bob"}}) {
+		t.Errorf("expected the new parameter name, got %v", params)
+	}
+	if routes.Len() != 1 {
+		t.Errorf("expected 1 pattern, got %d", routes.Len())
+	}
+
+	for _, pattern := range []string{"/users/:", "/files/*", "/files/*path/more", "/a/:x/:x"} {
+		if _, _, err := routes.Insert(pattern, 0); !errors.Is(err, ErrInvalidPattern) {
+			t.Errorf("%s: expected %v, got %v", pattern, ErrInvalidPattern, err)
+		}
+	}
+	if routes.Len() != 1 {
+		t.Errorf("expected invalid patterns not to be inserted, got %d patterns", routes.Len())
+	}
+}
+
+func TestSegmentTreeRemove(t *testing.T) {
+	routes := NewSegmentTree[int]()
+	routes.Insert("/users/:id", 1)
+	routes.Insert("/users/:id/edit", 2)
+	routes.Insert("/files/*path", 3)
+
+	if old, removed := routes.Remove("/users/:other"); !removed || old != 1 {
+		t.Errorf("expected to remove 1, got %d %v", old, removed)
+	}
+	if _, _, found := routes.Lookup("/users/42"); found {
+		t.Errorf("expected /users/42 not to match after removal")
+	}
+	if _, _, found := routes.Lookup("/users/42/edit"); !found {
+		t.Errorf("expected /users/42/edit to still match")
+	}
+	if _, removed := routes.Remove("/users/:id"); removed {
+		t.Errorf("expected removing twice to fail")
+	}
+	if old, removed := routes.Remove("/files/*rest"); !removed || old != 3 {
+		t.Errorf("expected to remove 3, got %d %v", old, removed)
+	}
+	routes.Remove("/users/:id/edit")
+	if routes.Len() != 0 || !routes.root.isEmpty() {
+		t.Errorf("expected an empty tree, got %d patterns", routes.Len())
+	}
+}
+
+func TestSegmentTreeSeparatorAndWalk(t *testing.T) {
+	topics := NewSegmentTree[int](WithSeparator('.'))
+	topics.Insert("orders.*rest", 4)
+	topics.Insert("orders.:region.created", 3)
+	topics.Insert("orders.eu.created", 2)
+	topics.Insert("orders.us.created", 1)
+
+	if value, params, _ := topics.Lookup("orders.asia.created"); value != 3 || !reflect.DeepEqual(params, Params{{"region", "asia"}}) {
+		t.Errorf("expected 3 with region asia, got %d %v", value, params)
+	}
+
+	var patterns []string
+	topics.Walk(func(pattern string, value int) bool {
+		patterns = append(patterns, pattern)
+		return true
+	})
+	expected := []string{"orders.eu.created", "orders.us.created", "orders.:region.created", "orders.*rest"}
+	if !reflect.DeepEqual(patterns, expected) {
+		t.Errorf("expected %v, got %v", expected, patterns)
+	}
+}
+
+func BenchmarkSegmentTreeLookup(b *testing.B) {
+	routes := NewSegmentTree[int]()
+	routes.Insert("/api/v1/users", 0)
+	routes.Insert("/api/v1/users/:id", 1)
+	routes.Insert("/api/v1/users/:id/posts/:post", 2)
+	routes.Insert("/static/*path", 3)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		routes.Lookup("/api/v1/users/42/posts/7")
+	}
+}
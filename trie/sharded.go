@@ -0,0 +1,242 @@
+package trie
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// shardCount is the number of shards of a ShardedTree.
+const shardCount = 256
+
+// ShardedTree is a thread-safe Trie that spreads its keys over independent concurrent Trees, each with its own lock, so
+// operations on keys in different shards never contend.
+//
+// By default a key is filed under the shard of its first byte, which keeps the shards in key order. WithShardPrefix files
+// keys by a hash of a longer prefix instead, which balances keys sharing their first byte at the cost of merging the shards
+// when iterating over prefixes shorter than the hashed one.
+type ShardedTree[T any] struct {
+	shards      []Tree[T]
+	shardPrefix int
+}
+
+// NewShardedTree creates and returns a new ShardedTree instance. The options apply to every shard, so a capacity bound set
+// with WithMaxEntries or WithMaxBytes bounds each of the 256 shards separately, and the ShardedTree as a whole holds up to
+// 256 times as much.
+func NewShardedTree[T any](opts ...Option) *ShardedTree[T] {
+	o := newOptions(opts)
+	o.check("NewShardedTree", scopeClock|scopeTree|scopeShard)
+	shards := make([]Tree[T], shardCount)
+	for i := range shards {
+		shards[i] = newTree[T](o, true)
+	}
+	return &ShardedTree[T]{shards: shards, shardPrefix: o.shardPrefix}
+}
+
+// WithShardPrefix makes a ShardedTree file keys under a hash of their first length bytes instead of under their first byte.
+// Keys shorter than length are hashed whole. It panics if length is less than 1.
+func WithShardPrefix(length int) Option {
+	if length < 1 {
+		panic(fmt.Sprintf("trie: shard prefix length must be at least 1, got %d", length))
+	}
+	return func(o *options) {
+		o.set("WithShardPrefix", scopeShard)
+		o.shardPrefix = length
+	}
+}
+
+// Insert adds a key-value pair to the Trie. It returns the old value (if any) and a boolean indicating if a value was replaced.
+func (t *ShardedTree[T]) Insert(key string, value T) (oldValue T, replaced bool) {
+	return t.shard(key).Insert(key, value)
+}
+
+// InsertWithExpiry adds a key-value pair to the Trie with an expiry duration. It returns the old value (if any) and a boolean indicating if a value was replaced.
+func (t *ShardedTree[T]) InsertWithExpiry(key string, value T, expiry time.Duration) (oldValue T, replaced bool) {
+	return t.shard(key).InsertWithExpiry(key, value, expiry)
+}
+
+// Find retrieves the value associated with the given key. It returns false if the key does not exist or the value has expired.
+func (t *ShardedTree[T]) Find(key string) (value T, found bool) {
+	return t.shard(key).Find(key)
+}
+
+// Remove deletes the key-value pair from the Trie. It returns the old value (if any) and a boolean indicating if a value was removed.
+func (t *ShardedTree[T]) Remove(key string) (oldValue T, removed bool) {
+	return t.shard(key).Remove(key)
+}
+
+// Len returns the number of values stored in the Trie. Values that have expired count until they are reclaimed by Find or Sweep.
+// The shards are counted one after another, so the result is not atomic with respect to concurrent writes.
+func (t *ShardedTree[T]) Len() int {
+	n := 0
+	for i := range t.shards {
+		n += t.shards[i].Len()
+	}
+	return n
+}
+
+// Sweep removes every expired entry from every shard. It returns the number of removed entries.
+func (t *ShardedTree[T]) Sweep() int {
+	removed := 0
+	for i := range t.shards {
+		removed += t.shards[i].Sweep()
+	}
+	return removed
+}
+
+// FindPrefix returns the non-expired entries whose keys start with prefix, in lexicographic byte order of the keys. If limit is greater than zero, at most limit entries are returned.
+func (t *ShardedTree[T]) FindPrefix(prefix string, limit int) []Entry[T] {
+	var entries []Entry[T]
+	t.walkPrefix(prefix, limit, func(key string, value T) bool {
+		entries = append(entries, Entry[T]{Key: key, Value: value})
+		return limit <= 0 || len(entries) < limit
+	})
+	return entries
+}
+
+// KeysWithPrefix returns the keys of the non-expired entries that start with prefix, in lexicographic byte order. If limit is greater than zero, at most limit keys are returned.
+func (t *ShardedTree[T]) KeysWithPrefix(prefix string, limit int) []string {
+	var keys []string
+	t.walkPrefix(prefix, limit, func(key string, _ T) bool {
+		keys = append(keys, key)
+		return limit <= 0 || len(keys) < limit
+	})
+	return keys
+}
+
+// Walk calls fn for every non-expired entry in the Trie, in lexicographic byte order of the keys. Walking stops as soon as fn returns false.
+// Each shard is locked for reading only while it is visited, so fn must not modify the shard it is called from.
+func (t *ShardedTree[T]) Walk(fn func(key string, value T) bool) {
+	t.walkPrefix("", 0, fn)
+}
+
+// walkPrefix calls fn in key order for every non-expired entry under prefix, of which the caller needs at most limit if limit
+// is greater than zero. Walking stops when fn returns false.
+func (t *ShardedTree[T]) walkPrefix(prefix string, limit int, fn func(key string, value T) bool) {
+	switch {
+	case len(prefix) >= t.shardPrefix:
+		// every key under prefix shares the bytes the shard is chosen by
+		t.shard(prefix).walkPrefix(prefix, func(key []byte, value T) bool {
+			return fn(string(key), value)
+		})
+	case t.shardPrefix == 1:
+		// shards chosen by the first byte are already in key order
+		for i := range t.shards {
+			stopped := false
+			t.shards[i].walkPrefix(prefix, func(key []byte, value T) bool {
+				stopped = !fn(string(key), value)
+				return !stopped
+			})
+			if stopped {
+				return
+			}
+		}
+	default:
+		t.mergePrefix(prefix, limit, fn)
+	}
+}
+
+// mergePrefix calls fn in key order for the entries under prefix from every shard. It reads the shards a batch at a time, so
+// it holds at most a batch of entries per shard, and no more than limit if limit is greater than zero: the first limit entries
+// of the merge are among the first limit entries of the shards.
+func (t *ShardedTree[T]) mergePrefix(prefix string, limit int, fn func(key string, value T) bool) {
+	size := shardBatch
+	if limit > 0 && limit < size {
+		size = limit
+	}
+	cursors := make(shardCursors[T], 0, len(t.shards))
+	for i := range t.shards {
+		c := &shardCursor[T]{shard: &t.shards[i], prefix: prefix, next: prefix}
+		if c.fill(size) {
+			cursors = append(cursors, c)
+		}
+	}
+	heap.Init(&cursors)
+	for len(cursors) > 0 {
+		c := cursors[0]
+		entry := c.entries[c.pos]
+		if !fn(entry.Key, entry.Value) {
+			return
+		}
+		if c.pos++; c.pos < len(c.entries) || c.fill(size) {
+			heap.Fix(&cursors, 0)
+		} else {
+			heap.Pop(&cursors)
+		}
+	}
+}
+
+// shard returns the shard key is filed under.
+func (t *ShardedTree[T]) shard(key string) *Tree[T] {
+	if t.shardPrefix == 1 {
+		if key == "" {
+			return &t.shards[0]
+		}
+		return &t.shards[key[0]]
+	}
+	if len(key) > t.shardPrefix {
+		key = key[:t.shardPrefix]
+	}
+	// FNV-1a
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &t.shards[h%shardCount]
+}
+
+// shardBatch is the number of entries mergePrefix reads from a shard at a time.
+const shardBatch = 64
+
+// shardCursor reads the entries under a prefix from a shard in key order, a batch at a time.
+type shardCursor[T any] struct {
+	shard   *Tree[T]
+	prefix  string
+	next    string     // the smallest key that has not been read yet
+	done    bool       // whether the shard has no entries under prefix from next on
+	entries []Entry[T] // the batch being merged
+	pos     int        // the position of the next entry of the batch
+}
+
+// fill reads the next batch of up to size entries from the shard, and reports whether it read any.
+func (c *shardCursor[T]) fill(size int) bool {
+	if c.done {
+		return false
+	}
+	c.entries, c.pos = c.entries[:0], 0
+	c.shard.Range(c.next, "", func(key string, value T) bool {
+		if !strings.HasPrefix(key, c.prefix) {
+			return false
+		}
+		c.entries = append(c.entries, Entry[T]{Key: key, Value: value})
+		return len(c.entries) < size
+	})
+	if len(c.entries) < size {
+		c.done = true
+	} else {
+		// the smallest key after the last one read
+		c.next = c.entries[len(c.entries)-1].Key + "\x00"
+	}
+	return len(c.entries) > 0
+}
+
+// key returns the key of the next entry of the batch.
+func (c *shardCursor[T]) key() string {
+	return c.entries[c.pos].Key
+}
+
+// shardCursors is a min-heap of the shard cursors, ordered by the key of their next entry.
+type shardCursors[T any] []*shardCursor[T]
+
+func (c shardCursors[T]) Len() int           { return len(c) }
+func (c shardCursors[T]) Less(i, j int) bool { return c[i].key() < c[j].key() }
+func (c shardCursors[T]) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c *shardCursors[T]) Push(x any)        { *c = append(*c, x.(*shardCursor[T])) }
+func (c *shardCursors[T]) Pop() any {
+	old := *c
+	last := old[len(old)-1]
+	*c = old[:len(old)-1]
+	return last
+}
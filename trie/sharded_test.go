@@ -0,0 +1,179 @@
+package trie
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedTreeInsertFindRemove(t *testing.T) {
+	trie := NewShardedTree[string]()
+
+	oldValue, replaced := trie.Insert("hello", "world")
+	if replaced || oldValue != "" {
+		t.Errorf("expected replaced=false, oldValue='', got replaced=%v, oldValue=%v", replaced, oldValue)
+	}
+	oldValue, replaced = trie.Insert("hello", "universe")
+	if !replaced || oldValue != "world" {
+		t.Errorf("expected replaced=true, oldValue='world', got replaced=%v, oldValue=%v", replaced, oldValue)
+	}
+	trie.Insert("", "empty")
+	if value, found := trie.Find(""); !found || value != "empty" {
+		t.Errorf("expected found=true, value='empty', got found=%v, value=%v", found, value)
+	}
+
+	oldValue, removed := trie.Remove("hello")
+	if !removed || oldValue != "universe" {
+		t.Errorf("expected removed=true, oldValue='universe', got removed=%v, oldValue=%v", removed, oldValue)
+	}
+	if _, found := trie.Find("hello"); found {
+		t.Errorf("expected hello to be removed")
+	}
+	if trie.Len() != 1 {
+		t.Errorf("expected len=1, got len=%d", trie.Len())
+	}
+}
+
+func TestShardedTreeExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewShardedTree[int](WithClock(clock))
+	trie.InsertWithExpiry("a", 1, time.Minute)
+	trie.InsertWithExpiry("b", 2, time.Hour)
+
+	clock.Advance(2 * time.Minute)
+	if _, found := trie.Find("a"); found {
+		t.Errorf("expected a to be expired")
+	}
+	if removed := trie.Sweep(); removed != 0 {
+		t.Errorf("expected Find to have reclaimed a, got removed=%d", removed)
+	}
+	clock.Advance(time.Hour)
+	if removed := trie.Sweep(); removed != 1 {
+		t.Errorf("expected removed=1, got removed=%d", removed)
+	}
+	if trie.Len() != 0 {
+		t.Errorf("expected len=0, got len=%d", trie.Len())
+	}
+}
+
+func TestShardedTreeOrderedIteration(t *testing.T) {
+	keys := []string{"", "apple", "application", "apply", "banana", "band", "bandana", "cherry", "\x00", "\xff\xff"}
+	for _, opts := range [][]Option{nil, {WithShardPrefix(3)}} {
+		trie := NewShardedTree[int](opts...)
+		for i, key := range keys {
+			trie.Insert(key, i)
+		}
+		sorted := append([]string(nil), keys...)
+		sort.Strings(sorted)
+
+		// Walk visits every shard in key order
+		var walked []string
+		trie.Walk(func(key string, _ int) bool {
+			walked = append(walked, key)
+			return true
+		})
+		if !reflect.DeepEqual(walked, sorted) {
+			t.Errorf("expected %q, got %q", sorted, walked)
+		}
+
+		// Prefixes shorter and longer than the shard prefix
+		if got := trie.KeysWithPrefix("ap", 0); !reflect.DeepEqual(got, []string{"apple", "application", "apply"}) {
+			t.Errorf("expected [apple application apply], got %q", got)
+		}
+		if got := trie.KeysWithPrefix("band", 0); !reflect.DeepEqual(got, []string{"band", "bandana"}) {
+			t.Errorf("expected [band bandana], got %q", got)
+		}
+		if got := trie.KeysWithPrefix("", 4); !reflect.DeepEqual(got, sorted[:4]) {
+			t.Errorf("expected %q, got %q", sorted[:4], got)
+		}
+		entries := trie.FindPrefix("b", 2)
+		if len(entries) != 2 || entries[0].Key != "banana" || entries[1].Key != "band" {
+			t.Errorf("expected [banana band], got %v", entries)
+		}
+	}
+}
+
+func TestShardedTreeMergeBatches(t *testing.T) {
+	// keys sharing their first 3 bytes share a shard, which is then read in several batches
+	trie := NewShardedTree[int](WithShardPrefix(3))
+	var sorted []string
+	for i := 0; i < 3*shardBatch; i++ {
+		for _, prefix := range []string{"abc", "xyz", "k"} {
+			key := fmt.Sprintf("%s%03d", prefix, i)
+			trie.Insert(key, i)
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Strings(sorted)
+	var walked []string
+	trie.Walk(func(key string, _ int) bool {
+		walked = append(walked, key)
+		return true
+	})
+	if !reflect.DeepEqual(walked, sorted) {
+		t.Errorf("expected %d keys in order, got %d: %q", len(sorted), len(walked), walked)
+	}
+	if got := trie.KeysWithPrefix("", shardBatch+10); !reflect.DeepEqual(got, sorted[:shardBatch+10]) {
+		t.Errorf("expected %q, got %q", sorted[:shardBatch+10], got)
+	}
+
+	// the merge stops as soon as fn returns false
+	visited := 0
+	trie.Walk(func(string, int) bool {
+		visited++
+		return visited < 100
+	})
+	if visited != 100 {
+		t.Errorf("expected to stop after 100 keys, got %d", visited)
+	}
+}
+
+func TestShardedTreeConcurrency(t *testing.T) {
+	trie := NewShardedTree[int](WithShardPrefix(2))
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprintf("%d/%d", i, w)
+				trie.Insert(key, i)
+				trie.Find(key)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if trie.Len() != 4000 {
+		t.Errorf("expected len=4000, got len=%d", trie.Len())
+	}
+}
+
+func TestWithShardPrefixPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected WithShardPrefix(0) to panic")
+		}
+	}()
+	WithShardPrefix(0)
+}
+
+func BenchmarkMixedOperationsShardedParallel(b *testing.B) {
+	trie := NewShardedTree[string]()
+	for n := 0; n < 1000; n++ {
+		trie.Insert(fmt.Sprint(n), "value")
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		n := 0
+		for pb.Next() {
+			trie.Insert(fmt.Sprint(n%1000), "newvalue")
+			trie.Find(fmt.Sprint(n % 1000))
+			trie.Remove(fmt.Sprint(n % 1000))
+			n++
+		}
+	})
+}
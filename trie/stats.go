@@ -0,0 +1,53 @@
+package trie
+
+import (
+	"time"
+)
+
+// Stats describes the size and shape of a Tree.
+type Stats struct {
+	// Nodes is the number of allocated nodes, including the root.
+	Nodes int
+	// Values is the number of stored values, including those that have expired but have not been reclaimed yet.
+	Values int
+	// Expired is the number of stored values that have expired but have not been reclaimed yet.
+	Expired int
+	// MaxDepth is the length in bytes of the longest path from the root to a node, that is of the longest key the nodes spell
+	// out, whether or not a value is stored under it.
+	MaxDepth int
+}
+
+// Len returns the number of values stored in the Trie. Values that have expired count until they are reclaimed by Find or Sweep.
+func (t *Tree[T]) Len() int {
+	if t.syncSafe {
+		t.lock.RLock()
+		defer t.lock.RUnlock()
+	}
+	return t.values
+}
+
+// Stats returns the size and shape of the Trie. Nodes and Values are tracked as the Trie changes, while Expired and MaxDepth
+// are computed by visiting every node.
+func (t *Tree[T]) Stats() Stats {
+	if t.syncSafe {
+		t.lock.RLock()
+		defer t.lock.RUnlock()
+	}
+	stats := Stats{Nodes: t.nodes, Values: t.values}
+	t.root.measure(0, t.now(), &stats)
+	return stats
+}
+
+// measure adds the expired values and the depth of the subtree rooted at n, which lies depth bytes below the root, to stats.
+func (n *node[T]) measure(depth int, now time.Time, stats *Stats) {
+	if depth > stats.MaxDepth {
+		stats.MaxDepth = depth
+	}
+	if n.isEnd && n.value.expired(now) {
+		stats.Expired++
+	}
+	n.forEachChild(func(_ byte, child *node[T]) bool {
+		child.measure(depth+len(child.prefix), now, stats)
+		return true
+	})
+}
@@ -0,0 +1,129 @@
+package trie
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// countValues returns the number of values stored in the subtree rooted at n, expired or not.
+func countValues[T any](n *node[T]) int {
+	count := 0
+	if n.isEnd {
+		count++
+	}
+	n.forEachChild(func(_ byte, child *node[T]) bool {
+		count += countValues(child)
+		return true
+	})
+	return count
+}
+
+func TestLen(t *testing.T) {
+	trie := NewTree[int]()
+	if trie.Len() != 0 {
+		t.Errorf("expected len=0, got len=%d", trie.Len())
+	}
+
+	trie.Insert("one", 1)
+	trie.Insert("two", 2)
+	trie.InsertB([]byte("three"), 3)
+	if trie.Len() != 3 {
+		t.Errorf("expected len=3, got len=%d", trie.Len())
+	}
+
+	// Replacing a value does not change the length
+	trie.Insert("one", 11)
+	if trie.Len() != 3 {
+		t.Errorf("expected len=3, got len=%d", trie.Len())
+	}
+
+	trie.Remove("two")
+	trie.Remove("missing")
+	if trie.Len() != 2 {
+		t.Errorf("expected len=2, got len=%d", trie.Len())
+	}
+}
+
+func TestLenWithExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[int](WithClock(clock))
+	trie.Insert("permanent", 0)
+	trie.InsertWithExpiry("found", 1, time.Second)
+	trie.InsertWithExpiry("swept", 2, time.Second)
+	clock.Advance(time.Minute)
+
+	// Expired values count until they are reclaimed
+	if trie.Len() != 3 {
+		t.Errorf("expected len=3, got len=%d", trie.Len())
+	}
+
+	trie.Find("found")
+	if trie.Len() != 2 {
+		t.Errorf("expected len=2, got len=%d", trie.Len())
+	}
+
+	trie.Sweep()
+	if trie.Len() != 1 {
+		t.Errorf("expected len=1, got len=%d", trie.Len())
+	}
+}
+
+func TestStats(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[int](WithClock(clock))
+
+	stats := trie.Stats()
+	if stats != (Stats{Nodes: 1}) {
+		t.Errorf("expected stats=%+v, got stats=%+v", Stats{Nodes: 1}, stats)
+	}
+
+	trie.Insert("hello", 1)
+	trie.Insert("help", 2)
+	trie.InsertWithExpiry("helpers", 3, time.Second)
+	clock.Advance(time.Minute)
+
+	expected := Stats{Nodes: 5, Values: 3, Expired: 1, MaxDepth: 7}
+	if stats := trie.Stats(); stats != expected {
+		t.Errorf("expected stats=%+v, got stats=%+v", expected, stats)
+	}
+
+	trie.Sweep()
+	expected = Stats{Nodes: 4, Values: 2, Expired: 0, MaxDepth: 5}
+	if stats := trie.Stats(); stats != expected {
+		t.Errorf("expected stats=%+v, got stats=%+v", expected, stats)
+	}
+}
+
+func TestStatsMatchTree(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[int](WithClock(clock))
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 5000; i++ {
+		key := fmt.Sprint(rng.Intn(500))
+		switch rng.Intn(5) {
+		case 0, 1:
+			trie.Insert(key, i)
+		case 2:
+			trie.InsertWithExpiry(key, i, time.Duration(rng.Intn(10))*time.Second)
+		case 3:
+			trie.Remove(key)
+		case 4:
+			trie.Find(key)
+		}
+		if i%500 == 0 {
+			clock.Advance(5 * time.Second)
+			trie.Sweep()
+		}
+
+		stats := trie.Stats()
+		if nodes := countNodes(trie.root); stats.Nodes != nodes {
+			t.Fatalf("step %d: expected nodes=%d, got nodes=%d", i, nodes, stats.Nodes)
+		}
+		if values := countValues(trie.root); stats.Values != values || trie.Len() != values {
+			t.Fatalf("step %d: expected values=%d, got values=%d len=%d", i, values, stats.Values, trie.Len())
+		}
+	}
+}
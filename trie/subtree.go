@@ -0,0 +1,128 @@
+package trie
+
+import (
+	"sync"
+	"time"
+)
+
+// RemovePrefix deletes every key that starts with prefix, releasing the nodes that are no longer needed, and returns the number
+// of removed values, including expired values that had not been reclaimed yet. The removed values are reported to the OnEvict
+// callback with EvictRemoved, or EvictExpired if they had already expired. An empty prefix removes everything.
+func (t *Tree[T]) RemovePrefix(prefix string) int {
+	if t.syncSafe {
+		t.lock.Lock()
+	}
+	now := t.now()
+	var cut *node[T]
+	var path []byte
+	var pruned int
+	if prefix == "" {
+		cut = t.root
+		t.root = newNode[T](t.gen, nil)
+		pruned = -1 // the new root takes the place of the old one
+	} else if target, targetPath := t.root.seek(prefix); target != nil {
+		t.root = t.root.writable(t.gen)
+		cut, pruned = t.root.cutPath(t.gen, prefix)
+		path = targetPath
+	}
+	if cut == nil {
+		if t.syncSafe {
+			t.lock.Unlock()
+		}
+		return 0
+	}
+	nodes, values := cut.count()
+	t.nodes -= nodes + pruned
+	t.values -= values
+	var removed []eviction[T]
+	if t.onEvict != nil || t.bound != nil || t.watch != nil {
+		// no value has expired at the zero time, so every removed value is visited
+		cut.walkEntries(path, time.Time{}, func(key []byte, entry *valueWithExpiry[T]) bool {
+			t.dropped(string(key), entry)
+			if t.onEvict != nil {
+				removed = append(removed, eviction[T]{key: string(key), entry: entry})
+			}
+			return true
+		})
+	}
+	if t.syncSafe {
+		t.lock.Unlock()
+	}
+	for _, e := range removed {
+		t.evicted(e.key, e.entry, now, EvictRemoved)
+	}
+	return values
+}
+
+// Subtree returns a new Tree holding the entries whose keys start with prefix. If rebase is set, prefix is stripped from their
+// keys. The new Tree has the same configuration as t, is concurrent if t is, and is independent of t: they share their nodes
+// copy-on-write, so extracting a subtree copies nothing up front and later writes to either Tree do not affect the other.
+func (t *Tree[T]) Subtree(prefix string, rebase bool) Tree[T] {
+	if t.syncSafe {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+	}
+	gen := nextGen()
+	sub := Tree[T]{
+		contents: &contents[T]{root: newNode[T](gen, nil), gen: gen, nodes: 1},
+		syncSafe: t.syncSafe,
+		clock:    t.clock,
+		onEvict:  t.onEvict,
+		codec:    t.codec,
+		bound:    t.bound.clone(),
+	}
+	if t.syncSafe {
+		sub.lock = &sync.RWMutex{}
+	}
+	target, path := t.root.seek(prefix)
+	if target == nil {
+		return sub
+	}
+	// the nodes below target are now shared, so t has to copy them before changing them
+	t.gen = nextGen()
+	label := path
+	if rebase {
+		label = path[len(prefix):]
+	}
+	top := target.writable(gen)
+	nodes, values := top.count()
+	if len(label) == 0 {
+		top.prefix = nil
+		sub.root, sub.nodes = top, nodes
+	} else {
+		top.prefix = label
+		sub.root.setChild(top)
+		sub.nodes += nodes
+	}
+	sub.values = values
+	sub.reset()
+	return sub
+}
+
+// cutPath detaches the subtree holding the keys that start with prefix, which must be non-empty and lead to a node, and compacts
+// the nodes on the path to it. n must belong to gen. It returns the detached subtree and the number of pruned nodes.
+func (n *node[T]) cutPath(gen uint64, prefix string) (cut *node[T], pruned int) {
+	child := n.child(prefix[0])
+	if len(prefix) <= len(child.prefix) {
+		n.removeChild(prefix[0])
+		return child, 0
+	}
+	owned := n.own(gen, child)
+	cut, pruned = owned.cutPath(gen, prefix[len(child.prefix):])
+	return cut, pruned + n.compact(gen, owned)
+}
+
+// count returns the number of nodes and values in the subtree rooted at n.
+func (n *node[T]) count() (nodes, values int) {
+	nodes = 1
+	if n.isEnd {
+		values = 1
+	}
+	n.forEachChild(func(_ byte, child *node[T]) bool {
+		childNodes, childValues := child.count()
+		nodes += childNodes
+		values += childValues
+		return true
+	})
+	return nodes, values
+}
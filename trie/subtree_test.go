@@ -0,0 +1,209 @@
+package trie
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// checkCounts verifies that the node and value counters of trie match its nodes.
+func checkCounts[T any](t *testing.T, trie *Tree[T]) {
+	t.Helper()
+	if nodes := countNodes(trie.root); nodes != trie.nodes {
+		t.Errorf("expected nodes=%d, got nodes=%d", nodes, trie.nodes)
+	}
+	if values := countValues(trie.root); values != trie.values {
+		t.Errorf("expected values=%d, got values=%d", values, trie.values)
+	}
+	checkCompressed(t, trie.root, true)
+}
+
+func TestRemovePrefix(t *testing.T) {
+	log := &evictionLog{}
+	trie := NewTree[string](WithOnEvict(log.record))
+	trie.Insert("tenant/1/a", "1a")
+	trie.Insert("tenant/1/b", "1b")
+	trie.Insert("tenant/10/a", "10a")
+	trie.Insert("tenant/2/a", "2a")
+
+	if removed := trie.RemovePrefix("tenant/1/"); removed != 2 {
+		t.Errorf("expected removed=2, got removed=%d", removed)
+	}
+	if keys := trie.KeysWithPrefix("", 0); !reflect.DeepEqual(keys, []string{"tenant/10/a", "tenant/2/a"}) {
+		t.Errorf("expected [tenant/10/a tenant/2/a], got %v", keys)
+	}
+	if events := log.take(); !reflect.DeepEqual(events, []string{"tenant/1/a=1a:removed", "tenant/1/b=1b:removed"}) {
+		t.Errorf("expected [tenant/1/a=1a:removed tenant/1/b=1b:removed], got %v", events)
+	}
+	checkCounts(t, &trie)
+
+	// A prefix ending inside an edge removes the whole edge
+	if removed := trie.RemovePrefix("tenant/1"); removed != 1 {
+		t.Errorf("expected removed=1, got removed=%d", removed)
+	}
+	if removed := trie.RemovePrefix("missing"); removed != 0 {
+		t.Errorf("expected removed=0, got removed=%d", removed)
+	}
+	checkCounts(t, &trie)
+
+	// An empty prefix removes everything
+	trie.Insert("", "root")
+	if removed := trie.RemovePrefix(""); removed != 2 {
+		t.Errorf("expected removed=2, got removed=%d", removed)
+	}
+	if trie.Len() != 0 || trie.nodes != 1 {
+		t.Errorf("expected an empty Trie, got len=%d and %d nodes", trie.Len(), trie.nodes)
+	}
+}
+
+func TestRemovePrefixRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	trie := NewTree[int]()
+	keys := map[string]bool{}
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("%x", rng.Intn(4096))
+		trie.Insert(key, i)
+		keys[key] = true
+	}
+	for _, prefix := range []string{"a", "1f", "ff", "3", "b0", "c1"} {
+		expected := 0
+		for key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				delete(keys, key)
+				expected++
+			}
+		}
+		if removed := trie.RemovePrefix(prefix); removed != expected {
+			t.Errorf("prefix %q: expected removed=%d, got removed=%d", prefix, expected, removed)
+		}
+		checkCounts(t, &trie)
+	}
+	if trie.Len() != len(keys) {
+		t.Errorf("expected len=%d, got len=%d", len(keys), trie.Len())
+	}
+}
+
+func TestRemovePrefixKeepsSnapshots(t *testing.T) {
+	trie := NewTree[int]()
+	trie.Insert("a/1", 1)
+	trie.Insert("a/2", 2)
+	snapshot := trie.Snapshot()
+	trie.RemovePrefix("a/")
+	if snapshot.Len() != 2 {
+		t.Errorf("expected the snapshot to keep 2 values, got %d", snapshot.Len())
+	}
+	if _, found := snapshot.Find("a/2"); !found {
+		t.Errorf("expected the snapshot to keep a/2")
+	}
+}
+
+func TestRemovePrefixCapacity(t *testing.T) {
+	trie := NewTree[int](WithMaxEntries(3))
+	trie.Insert("a/1", 1)
+	trie.Insert("a/2", 2)
+	trie.Insert("b", 3)
+	trie.RemovePrefix("a/")
+	trie.Insert("c", 4)
+	trie.Insert("d", 5)
+	if keys := trie.KeysWithPrefix("", 0); !reflect.DeepEqual(keys, []string{"b", "c", "d"}) {
+		t.Errorf("expected [b c d], got %v", keys)
+	}
+}
+
+func TestSubtree(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
+	trie.Insert("tenant/1/a", "1a")
+	trie.InsertWithExpiry("tenant/1/b", "1b", time.Minute)
+	trie.Insert("tenant/10/a", "10a")
+	trie.Insert("tenant/2/a", "2a")
+
+	sub := trie.Subtree("tenant/1/", false)
+	expected := []Entry[string]{{"tenant/1/a", "1a"}, {"tenant/1/b", "1b"}}
+	if entries := entriesOf(&sub); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected entries=%v, got entries=%v", expected, entries)
+	}
+	checkCounts(t, &sub)
+
+	rebased := trie.Subtree("tenant/1/", true)
+	expected = []Entry[string]{{"a", "1a"}, {"b", "1b"}}
+	if entries := entriesOf(&rebased); !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected entries=%v, got entries=%v", expected, entries)
+	}
+	checkCounts(t, &rebased)
+
+	// A prefix ending inside an edge keeps the rest of the edge
+	rebased = trie.Subtree("tenant/1", true)
+	if keys := rebased.KeysWithPrefix("", 0); !reflect.DeepEqual(keys, []string{"/a", "/b", "0/a"}) {
+		t.Errorf("expected [/a /b 0/a], got %v", keys)
+	}
+	checkCounts(t, &rebased)
+
+	// The subtree keeps expiry times and the clock
+	clock.Advance(time.Hour)
+	if _, found := sub.Find("tenant/1/b"); found {
+		t.Errorf("expected tenant/1/b to expire in the subtree")
+	}
+
+	if empty := trie.Subtree("missing", true); empty.Len() != 0 {
+		t.Errorf("expected an empty subtree, got len=%d", empty.Len())
+	}
+}
+
+func TestSubtreeIsIndependent(t *testing.T) {
+	trie := NewTree[int]()
+	for i := 0; i < 100; i++ {
+		trie.Insert(fmt.Sprintf("a/%d", i), i)
+	}
+	sub := trie.Subtree("a/", true)
+
+	// Writes to either Tree leave the other one alone
+	trie.Insert("a/1", -1)
+	trie.Remove("a/2")
+	sub.Insert("3", -3)
+	sub.Remove("4")
+	if value, _ := sub.Find("1"); value != 1 {
+		t.Errorf("expected the subtree to keep 1=1, got %d", value)
+	}
+	if _, found := sub.Find("2"); !found {
+		t.Errorf("expected the subtree to keep 2")
+	}
+	if value, _ := trie.Find("a/3"); value != 3 {
+		t.Errorf("expected the Tree to keep a/3=3, got %d", value)
+	}
+	if _, found := trie.Find("a/4"); !found {
+		t.Errorf("expected the Tree to keep a/4")
+	}
+	checkCounts(t, &trie)
+	checkCounts(t, &sub)
+}
+
+func TestRemovePrefixConcurrency(t *testing.T) {
+	trie := NewConcurrentTree[int]()
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				trie.Insert(fmt.Sprintf("%d/%d", w, i), i)
+				if i%50 == 49 {
+					trie.RemovePrefix(fmt.Sprintf("%d/", w))
+					sub := trie.Subtree(fmt.Sprintf("%d/", (w+1)%4), true)
+					sub.Insert("x", 0)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if trie.Len() != 0 {
+		keys := trie.KeysWithPrefix("", 0)
+		sort.Strings(keys)
+		t.Errorf("expected an empty Trie, got %v", keys)
+	}
+}
@@ -0,0 +1,96 @@
+package trie
+
+import (
+	"fmt"
+	"time"
+)
+
+// janitor periodically sweeps expired entries from a Tree.
+type janitor struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Sweep removes every expired entry from the Trie and releases the nodes that are no longer needed. It returns the number of removed entries.
+// Swept entries are reported to the OnEvict callback with EvictExpired.
+func (t *Tree[T]) Sweep() int {
+	if t.syncSafe {
+		t.lock.Lock()
+	}
+	now := t.now()
+	var expired []Entry[T]
+	var collect func(key []byte, value *valueWithExpiry[T])
+	if t.onEvict != nil || t.bound != nil || t.watch != nil {
+		collect = func(key []byte, value *valueWithExpiry[T]) {
+			t.dropped(string(key), value)
+			if t.onEvict != nil {
+				expired = append(expired, Entry[T]{Key: string(key), Value: value.value})
+			}
+		}
+	}
+	var removed, pruned int
+	t.root, removed, pruned = t.root.sweep(t.gen, make([]byte, 0, 64), now, collect)
+	t.values -= removed
+	t.nodes -= pruned
+	if t.syncSafe {
+		t.lock.Unlock()
+	}
+	for _, entry := range expired {
+		t.onEvict(entry.Key, entry.Value, EvictExpired)
+	}
+	return removed
+}
+
+// StartJanitor starts a background goroutine that calls Sweep every interval until StopJanitor is called.
+// A running janitor is stopped and replaced. The janitor shares the Tree with its callers, so it panics on a Tree
+// that was not created with NewConcurrentTree. It also panics if interval is not positive. StartJanitor and StopJanitor
+// may be called concurrently.
+func (t *Tree[T]) StartJanitor(interval time.Duration) {
+	if !t.syncSafe {
+		panic("trie: StartJanitor requires a tree created with NewConcurrentTree")
+	}
+	if interval <= 0 {
+		panic(fmt.Sprintf("trie: janitor interval must be positive, got %v", interval))
+	}
+	j := &janitor{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(j.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.Sweep()
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+	t.lock.Lock()
+	old := t.janitor
+	t.janitor = j
+	t.lock.Unlock()
+	old.halt()
+}
+
+// StopJanitor stops the janitor started by StartJanitor and waits for it to exit. It does nothing if no janitor is running.
+func (t *Tree[T]) StopJanitor() {
+	if !t.syncSafe {
+		return
+	}
+	// the janitor is taken out under the lock, so only one caller stops it, but waited for without it, since it sweeps
+	t.lock.Lock()
+	j := t.janitor
+	t.janitor = nil
+	t.lock.Unlock()
+	j.halt()
+}
+
+// halt stops the janitor and waits for it to exit. j may be nil.
+func (j *janitor) halt() {
+	if j == nil {
+		return
+	}
+	close(j.stop)
+	<-j.done
+}
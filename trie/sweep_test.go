@@ -0,0 +1,167 @@
+package trie
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSweep(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
+	trie.Insert("permanent", "value")
+	trie.InsertWithExpiry("session/1", "a", time.Minute)
+	trie.InsertWithExpiry("session/2", "b", time.Hour)
+
+	// Nothing has expired yet
+	if removed := trie.Sweep(); removed != 0 {
+		t.Errorf("expected removed=0, got removed=%d", removed)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if removed := trie.Sweep(); removed != 1 {
+		t.Errorf("expected removed=1, got removed=%d", removed)
+	}
+	if _, found := trie.Find("session/1"); found {
+		t.Errorf("expected session/1 to be swept")
+	}
+	value, found := trie.Find("session/2")
+	if !found || value != "b" {
+		t.Errorf("expected found=true, value='b', got found=%v, value=%v", found, value)
+	}
+
+	clock.Advance(2 * time.Hour)
+	if removed := trie.Sweep(); removed != 1 {
+		t.Errorf("expected removed=1, got removed=%d", removed)
+	}
+
+	// Only the permanent key is left, so the session branch has been pruned
+	if nodes := countNodes(trie.root); nodes != 2 {
+		t.Errorf("expected 2 nodes, got %d", nodes)
+	}
+}
+
+func TestSweepKeepsDescendants(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
+	trie.InsertWithExpiry("app", "partial", time.Second)
+	trie.Insert("apple", "complete")
+
+	clock.Advance(time.Minute)
+	if removed := trie.Sweep(); removed != 1 {
+		t.Errorf("expected removed=1, got removed=%d", removed)
+	}
+	value, found := trie.Find("apple")
+	if !found || value != "complete" {
+		t.Errorf("expected found=true, value='complete', got found=%v, value=%v", found, value)
+	}
+	// "app" is merged back into "apple"
+	if nodes := countNodes(trie.root); nodes != 2 {
+		t.Errorf("expected 2 nodes, got %d", nodes)
+	}
+}
+
+func TestClockDrivesExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
+	trie.InsertWithExpiry("temp", "data", time.Second)
+
+	value, found := trie.Find("temp")
+	if !found || value != "data" {
+		t.Errorf("expected found=true, value='data', got found=%v, value=%v", found, value)
+	}
+
+	clock.Advance(2 * time.Second)
+	value, found = trie.Find("temp")
+	if found || value != "" {
+		t.Errorf("expected found=false, value='', got found=%v, value=%v", found, value)
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewConcurrentTree[int](WithClock(clock))
+	for i := 0; i < 100; i++ {
+		trie.InsertWithExpiry(fmt.Sprint(i), i, time.Minute)
+	}
+	clock.Advance(time.Hour)
+
+	trie.StartJanitor(time.Millisecond)
+	defer trie.StopJanitor()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for countNodesLocked(&trie) > 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor did not sweep the expired entries")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStopJanitor(t *testing.T) {
+	trie := NewConcurrentTree[int]()
+
+	// Stopping without a running janitor is a no-op
+	trie.StopJanitor()
+
+	trie.StartJanitor(time.Millisecond)
+	trie.StartJanitor(time.Millisecond)
+	trie.StopJanitor()
+	if trie.janitor != nil {
+		t.Errorf("expected janitor to be stopped")
+	}
+}
+
+func TestJanitorRequiresConcurrentTree(t *testing.T) {
+	trie := NewTree[int]()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected StartJanitor to panic on a non-thread-safe tree")
+		}
+	}()
+	trie.StartJanitor(time.Second)
+}
+
+func TestJanitorInvalidInterval(t *testing.T) {
+	trie := NewConcurrentTree[int]()
+	for _, interval := range []time.Duration{0, -time.Second} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected StartJanitor(%v) to panic", interval)
+				}
+			}()
+			trie.StartJanitor(interval)
+		}()
+	}
+	if trie.janitor != nil {
+		t.Errorf("expected no janitor to be started")
+	}
+}
+
+func TestJanitorConcurrentStartStop(t *testing.T) {
+	trie := NewConcurrentTree[int]()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				trie.StartJanitor(time.Millisecond)
+				trie.StopJanitor()
+			}
+		}()
+	}
+	wg.Wait()
+	if trie.janitor != nil {
+		t.Errorf("expected janitor to be stopped")
+	}
+}
+
+// countNodesLocked counts the nodes of a concurrent tree while holding its read lock.
+func countNodesLocked[T any](trie *Tree[T]) int {
+	trie.lock.RLock()
+	defer trie.lock.RUnlock()
+	return countNodes(trie.root)
+}
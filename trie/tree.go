@@ -5,117 +5,235 @@ import (
 	"time"
 )
 
-// Tree represents a generic Trie (prefix Tree) structure.
+// Tree represents a generic Trie (prefix Tree) structure. Copies of a Tree share its contents, so a change made through one
+// copy is seen through all of them.
 type Tree[T any] struct {
-	root     *node[T]
+	*contents[T]
 	syncSafe bool
 	lock     *sync.RWMutex
+	clock    Clock
+	onEvict  func(key string, value T, reason EvictReason)
+	codec    Codec[T]
+	bound    *capacity[T]
+}
+
+// contents is the state of a Tree that changes as it is used, shared by the copies of the Tree.
+type contents[T any] struct {
+	root    *node[T]
+	gen     uint64
+	janitor *janitor
+	watch   *watchers[T]
+	nodes   int
+	values  int
 }
 
 // NewTree creates and returns a new non-thread-safe Tree instance.
-func NewTree[T any]() Tree[T] {
-	return Tree[T]{
-		root:     newNode[T](),
-		syncSafe: false,
-		lock:     nil,
-	}
+func NewTree[T any](opts ...Option) Tree[T] {
+	o := newOptions(opts)
+	o.check("NewTree", scopeClock|scopeTree|scopeCodec)
+	return newTree[T](o, false)
 }
 
 // NewConcurrentTree creates and returns a new thread-safe Tree instance.
-func NewConcurrentTree[T any]() Tree[T] {
-	return Tree[T]{
-		root:     newNode[T](),
-		syncSafe: true,
-		lock:     &sync.RWMutex{},
+func NewConcurrentTree[T any](opts ...Option) Tree[T] {
+	o := newOptions(opts)
+	o.check("NewConcurrentTree", scopeClock|scopeTree|scopeCodec)
+	return newTree[T](o, true)
+}
+
+// newTree creates a Tree configured by o, which is thread-safe if syncSafe is set.
+func newTree[T any](o *options, syncSafe bool) Tree[T] {
+	gen := nextGen()
+	t := Tree[T]{
+		contents: &contents[T]{root: newNode[T](gen, nil), gen: gen, nodes: 1},
+		syncSafe: syncSafe,
+		clock:    o.clock,
+		onEvict:  onEvictFor[T](o),
+		codec:    codecFor[T](o),
+		bound:    capacityFor[T](o),
+	}
+	if syncSafe {
+		t.lock = &sync.RWMutex{}
 	}
+	return t
 }
 
 // Insert adds a key-value pair to the Trie. It returns the old value (if any) and a boolean indicating if a value was replaced.
 func (t *Tree[T]) Insert(key string, value T) (oldValue T, replaced bool) {
-	return t.insert([]byte(key), value, nil)
+	return t.insert(key, value, nil)
 }
 
 // InsertWithExpiry adds a key-value pair to the Trie with an expiry duration. It returns the old value (if any) and a boolean indicating if a value was replaced.
 func (t *Tree[T]) InsertWithExpiry(key string, value T, expiry time.Duration) (oldValue T, replaced bool) {
-	expiryTime := time.Now().Add(expiry)
-	return t.insert([]byte(key), value, &expiryTime)
+	expiryTime := t.now().Add(expiry)
+	return t.insert(key, value, &expiryTime)
+}
+
+// InsertWithDeadline adds a key-value pair to the Trie that expires at the given time. It returns the old value (if any) and a boolean indicating if a value was replaced.
+func (t *Tree[T]) InsertWithDeadline(key string, value T, deadline time.Time) (oldValue T, replaced bool) {
+	return t.insert(key, value, &deadline)
+}
+
+// InsertWithSlidingExpiry adds a key-value pair to the Trie that expires once it has not been found for the ttl duration:
+// every successful Find pushes its expiry time back to ttl from then. It returns the old value (if any) and a boolean
+// indicating if a value was replaced.
+func (t *Tree[T]) InsertWithSlidingExpiry(key string, value T, ttl time.Duration) (oldValue T, replaced bool) {
+	expiryTime := t.now().Add(ttl)
+	return t.insertEntry(key, &valueWithExpiry[T]{value: value, expiry: &expiryTime, sliding: ttl})
 }
 
 // InsertB adds a key-value pair to the Trie using a byte slice key.
 func (t *Tree[T]) InsertB(key []byte, value T) (oldValue T, replaced bool) {
-	return t.insert(key, value, nil)
+	return t.insert(string(key), value, nil)
 }
 
 // InsertBWithExpiry adds a key-value pair to the Trie with an expiry duration using a byte slice key.
 func (t *Tree[T]) InsertBWithExpiry(key []byte, value T, expiry time.Duration) (oldValue T, replaced bool) {
-	expiryTime := time.Now().Add(expiry)
-	return t.insert(key, value, &expiryTime)
+	expiryTime := t.now().Add(expiry)
+	return t.insert(string(key), value, &expiryTime)
 }
 
 // Find retrieves the value associated with the given key. It returns nil if the key does not exist or the value has expired.
+// An expired value found this way is removed from the Trie, and a value with a sliding expiry has its expiry time pushed back.
 func (t *Tree[T]) Find(key string) (value T, found bool) {
-	if t.syncSafe {
-		t.lock.RLock()
-		defer t.lock.RUnlock()
+	entry, now := t.find(key)
+	if entry == nil {
+		return *new(T), false
 	}
-	zero := new(T)
-	node := t.root
-	for i := 0; i < len(key); i++ {
-		if node.children[key[i]] == nil {
-			return *zero, false
-		}
-		node = node.children[key[i]]
+	if entry.expired(now) {
+		t.expire(key, entry)
+		return *new(T), false
 	}
-	val, found := node.getValue()
-	if found {
-		return val, true
+	if entry.sliding > 0 {
+		return t.slide(key)
 	}
-	return *zero, false
+	return entry.value, true
 }
 
-// Remove deletes the key-value pair from the Trie. It returns the old value (if any) and a boolean indicating if a value was removed.
+// Remove deletes the key-value pair from the Trie, releasing the nodes that are no longer needed by any other key.
+// It returns the old value (if any) and a boolean indicating if a value was removed.
 func (t *Tree[T]) Remove(key string) (oldValue T, removed bool) {
 	if t.syncSafe {
 		t.lock.Lock()
-		defer t.lock.Unlock()
 	}
-	node := t.root
-	for i := 0; i < len(key); i++ {
-		if node.children[key[i]] == nil {
-			return *new(T), false
-		}
-		node = node.children[key[i]]
+	now := t.now()
+	var old *valueWithExpiry[T]
+	var pruned int
+	t.root, old, pruned = t.root.remove(t.gen, key, nil)
+	t.forget(key, old, pruned)
+	if t.syncSafe {
+		t.lock.Unlock()
 	}
-	if node.isEnd {
-		oldValue, _ = node.getValue()
-		node.isEnd = false
-		node.value = nil
-		removed = true
-	} else {
-		removed = false
+	if old == nil {
+		return *new(T), false
 	}
-	return oldValue, removed
+	t.evicted(key, old, now, EvictRemoved)
+	return old.value, true
 }
 
 // insert adds a key-value pair to the Trie with an optional expiry time. It returns the old value (if any) and a boolean indicating if a value was replaced.
-func (t *Tree[T]) insert(key []byte, value T, expiry *time.Time) (oldValue T, replaced bool) {
+func (t *Tree[T]) insert(key string, value T, expiry *time.Time) (oldValue T, replaced bool) {
+	return t.insertEntry(key, &valueWithExpiry[T]{value: value, expiry: expiry})
+}
+
+// insertEntry stores entry under key, evicting values if that takes the Tree over its capacity. It returns the old value (if any) and a boolean indicating if a value was replaced.
+func (t *Tree[T]) insertEntry(key string, entry *valueWithExpiry[T]) (oldValue T, replaced bool) {
+	if t.syncSafe {
+		t.lock.Lock()
+	}
+	now := t.now()
+	evictions := t.makeRoom(key, entry)
+	var node *node[T]
+	var created int
+	t.root, node, created = t.root.insert(t.gen, key)
+	t.nodes += created
+	old := node.value
+	node.setEntry(entry)
+	t.remember(key, old, entry)
+	evictions = append(evictions, t.shrink()...)
+	if t.syncSafe {
+		t.lock.Unlock()
+	}
+	t.evictedAll(evictions, now)
+	if old == nil {
+		return *new(T), false
+	}
+	t.evicted(key, old, now, EvictReplaced)
+	return old.value, true
+}
+
+// lookup returns the entry stored under key, expired or not, together with the time it was looked up at. It returns a nil entry if the key does not exist.
+func (t *Tree[T]) lookup(key string) (entry *valueWithExpiry[T], now time.Time) {
+	if t.syncSafe {
+		t.lock.RLock()
+		defer t.lock.RUnlock()
+	}
+	now = t.now()
+	node := t.root.descend(key)
+	if node == nil || !node.isEnd {
+		return nil, now
+	}
+	return node.value, now
+}
+
+// find is like lookup, but also tells the eviction policy that the value stored under key has been found if it has not expired
+// and has no sliding expiry, while holding the read lock, so the policy cannot be replaced by ReadFrom in the meantime.
+func (t *Tree[T]) find(key string) (entry *valueWithExpiry[T], now time.Time) {
+	if t.syncSafe {
+		t.lock.RLock()
+		defer t.lock.RUnlock()
+	}
+	now = t.now()
+	node := t.root.descend(key)
+	if node == nil || !node.isEnd {
+		return nil, now
+	}
+	if !node.value.expired(now) && node.value.sliding == 0 {
+		t.touched(key)
+	}
+	return node.value, now
+}
+
+// expire removes the expired entry stored under key, unless it has been replaced or removed in the meantime, and reports it to the OnEvict callback.
+func (t *Tree[T]) expire(key string, entry *valueWithExpiry[T]) {
 	if t.syncSafe {
 		t.lock.Lock()
-		defer t.lock.Unlock()
-	}
-	node := t.root
-	for i := 0; i < len(key); i++ {
-		if node.children[key[i]] == nil {
-			node.children[key[i]] = newNode[T]()
-		}
-		node = node.children[key[i]]
-	}
-	if node.isEnd {
-		oldValue, _ = node.getValue()
-		replaced = true
-	} else {
-		replaced = false
-	}
-	node.setValue(value, expiry)
-	return oldValue, replaced
+	}
+	now := t.now()
+	var old *valueWithExpiry[T]
+	var pruned int
+	t.root, old, pruned = t.root.remove(t.gen, key, entry)
+	t.forget(key, old, pruned)
+	if t.syncSafe {
+		t.lock.Unlock()
+	}
+	if old != nil {
+		t.evicted(key, old, now, EvictExpired)
+	}
+}
+
+// forget updates the size counters and the eviction policy after old was removed from key and pruned nodes were released. It must be called while holding the write lock.
+func (t *Tree[T]) forget(key string, old *valueWithExpiry[T], pruned int) {
+	if old != nil {
+		t.values--
+		t.dropped(key, old)
+	}
+	t.nodes -= pruned
+}
+
+// evicted reports a value that left the Trie at now to the OnEvict callback, if there is one. A value that had already expired is
+// always reported as EvictExpired. It must be called without holding the lock, so the callback can use the Tree.
+func (t *Tree[T]) evicted(key string, old *valueWithExpiry[T], now time.Time, reason EvictReason) {
+	if t.onEvict == nil {
+		return
+	}
+	if old.expired(now) {
+		reason = EvictExpired
+	}
+	t.onEvict(key, old.value, reason)
+}
+
+// now returns the current time according to the Tree's clock.
+func (t *Tree[T]) now() time.Time {
+	return t.clock.Now()
 }
@@ -2,6 +2,7 @@ package trie
 
 import (
 	"fmt"
+	"math/rand"
 	"testing"
 	"time"
 )
@@ -35,7 +36,8 @@ func TestInsertAndFind(t *testing.T) {
 }
 
 func TestInsertWithExpiry(t *testing.T) {
-	trie := NewTree[string]()
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
 
 	// Insert a value with an expiry of 1 second
 	oldValue, replaced := trie.InsertWithExpiry("temp", "data", 1*time.Second)
@@ -50,7 +52,7 @@ func TestInsertWithExpiry(t *testing.T) {
 	}
 
 	// Wait for expiry
-	time.Sleep(2 * time.Second)
+	clock.Advance(2 * time.Second)
 
 	// Find the value again
 	value, found = trie.Find("temp")
@@ -85,7 +87,8 @@ func TestRemove(t *testing.T) {
 }
 
 func TestExpiryDoesNotAffectOtherKeys(t *testing.T) {
-	trie := NewTree[string]()
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
 
 	// Insert a value without expiry
 	trie.Insert("permanent", "value")
@@ -94,7 +97,7 @@ func TestExpiryDoesNotAffectOtherKeys(t *testing.T) {
 	trie.InsertWithExpiry("temporary", "data", 1*time.Second)
 
 	// Wait for expiry
-	time.Sleep(2 * time.Second)
+	clock.Advance(2 * time.Second)
 
 	// Find the permanent value
 	value, found := trie.Find("permanent")
@@ -114,7 +117,8 @@ func TestEmptyTreeFind(t *testing.T) {
 }
 
 func TestOverwriteWithExpiry(t *testing.T) {
-	trie := NewTree[string]()
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
 
 	// Insert a value without expiry
 	oldValue, replaced := trie.Insert("key", "initial")
@@ -135,7 +139,7 @@ func TestOverwriteWithExpiry(t *testing.T) {
 	}
 
 	// Wait for expiry
-	time.Sleep(2 * time.Second)
+	clock.Advance(2 * time.Second)
 
 	// Find the value after expiry
 	value, found = trie.Find("key")
@@ -195,7 +199,8 @@ func TestInsertAndRemoveMultipleValues(t *testing.T) {
 }
 
 func TestOverwriteWithoutExpiry(t *testing.T) {
-	trie := NewTree[string]()
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
 
 	// Insert a value with expiry
 	oldValue, replaced := trie.InsertWithExpiry("key", "initial", 1*time.Second)
@@ -204,7 +209,7 @@ func TestOverwriteWithoutExpiry(t *testing.T) {
 	}
 
 	// Wait for expiry
-	time.Sleep(2 * time.Second)
+	clock.Advance(2 * time.Second)
 
 	// Overwrite the expired value without expiry
 	oldValue, replaced = trie.Insert("key", "updated")
@@ -220,7 +225,8 @@ func TestOverwriteWithoutExpiry(t *testing.T) {
 }
 
 func TestExpiredValueDoesNotReplaceNonExpiredValue(t *testing.T) {
-	trie := NewTree[string]()
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
 
 	// Insert a value with expiry
 	oldValue, replaced := trie.InsertWithExpiry("key", "initial", 1*time.Second)
@@ -235,7 +241,7 @@ func TestExpiredValueDoesNotReplaceNonExpiredValue(t *testing.T) {
 	}
 
 	// Wait for the original expiry to pass
-	time.Sleep(2 * time.Second)
+	clock.Advance(2 * time.Second)
 
 	// Ensure the non-expiring value is still present
 	value, found := trie.Find("key")
@@ -279,7 +285,8 @@ func TestConcurrency(t *testing.T) {
 }
 
 func TestNonExpiredValueDoesNotOverrideExistingValue(t *testing.T) {
-	trie := NewTree[string]()
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
 
 	// Insert a value without expiry
 	trie.Insert("key", "value")
@@ -291,7 +298,7 @@ func TestNonExpiredValueDoesNotOverrideExistingValue(t *testing.T) {
 	}
 
 	// Wait for the expiry to pass
-	time.Sleep(2 * time.Second)
+	clock.Advance(2 * time.Second)
 
 	// Ensure the key no longer exists
 	value, found := trie.Find("key")
@@ -300,6 +307,196 @@ func TestNonExpiredValueDoesNotOverrideExistingValue(t *testing.T) {
 	}
 }
 
+// countNodes returns the number of nodes in the subtree rooted at n, including n itself.
+func countNodes[T any](n *node[T]) int {
+	count := 1
+	n.forEachChild(func(_ byte, child *node[T]) bool {
+		count += countNodes(child)
+		return true
+	})
+	return count
+}
+
+// checkCompressed verifies that the subtree rooted at n is fully path-compressed: every child is filed under the first byte
+// of its non-empty prefix, and every node below the root either holds a value or branches.
+func checkCompressed[T any](t *testing.T, n *node[T], isRoot bool) {
+	t.Helper()
+	if !isRoot && !n.isEnd && n.children.len() < 2 {
+		t.Errorf("node %q holds no value and has %d children", n.prefix, n.children.len())
+	}
+	n.forEachChild(func(b byte, child *node[T]) bool {
+		if len(child.prefix) == 0 || child.prefix[0] != b {
+			t.Errorf("child %q is filed under %q", child.prefix, b)
+		}
+		checkCompressed(t, child, false)
+		return true
+	})
+}
+
+func TestInsertSplitsEdges(t *testing.T) {
+	trie := NewTree[int]()
+	trie.Insert("romane", 1)
+	trie.Insert("romanus", 2)
+	trie.Insert("romulus", 3)
+	trie.Insert("rubens", 4)
+	trie.Insert("ruber", 5)
+	trie.Insert("rubicon", 6)
+	trie.Insert("rubicundus", 7)
+	trie.Insert("r", 8)
+
+	checkCompressed(t, trie.root, true)
+	if nodes := countNodes(trie.root); nodes != 14 {
+		t.Errorf("expected 14 nodes, got %d", nodes)
+	}
+	for i, key := range []string{"romane", "romanus", "romulus", "rubens", "ruber", "rubicon", "rubicundus", "r"} {
+		value, found := trie.Find(key)
+		if !found || value != i+1 {
+			t.Errorf("expected found=true, value=%d, got found=%v, value=%v", i+1, found, value)
+		}
+	}
+
+	// Keys ending inside an edge or on a branching node without a value are not found
+	for _, key := range []string{"ro", "rom", "roman", "rub", "rubi", "romanes"} {
+		if _, found := trie.Find(key); found {
+			t.Errorf("expected %q not to be found", key)
+		}
+	}
+}
+
+func TestRandomOperationsMatchMap(t *testing.T) {
+	trie := NewTree[int]()
+	expected := map[string]int{}
+	rng := rand.New(rand.NewSource(1))
+	alphabet := "abc"
+
+	for i := 0; i < 20000; i++ {
+		key := make([]byte, rng.Intn(8))
+		for j := range key {
+			key[j] = alphabet[rng.Intn(len(alphabet))]
+		}
+		if rng.Intn(3) == 0 {
+			_, removed := trie.Remove(string(key))
+			_, exists := expected[string(key)]
+			if removed != exists {
+				t.Fatalf("remove %q: expected removed=%v, got removed=%v", key, exists, removed)
+			}
+			delete(expected, string(key))
+		} else {
+			trie.InsertB(key, i)
+			expected[string(key)] = i
+		}
+	}
+
+	checkCompressed(t, trie.root, true)
+	if trie.Len() != len(expected) {
+		t.Errorf("expected len=%d, got len=%d", len(expected), trie.Len())
+	}
+	for key, value := range expected {
+		if found, ok := trie.Find(key); !ok || found != value {
+			t.Errorf("expected found=true, value=%d, got found=%v, value=%v", value, ok, found)
+		}
+	}
+}
+
+func TestRemovePrunesEmptyBranches(t *testing.T) {
+	trie := NewTree[string]()
+	trie.Insert("hello", "world")
+	trie.Insert("help", "me")
+
+	// "hel" is shared, "lo" and "p" are not
+	if nodes := countNodes(trie.root); nodes != 4 {
+		t.Errorf("expected 4 nodes, got %d", nodes)
+	}
+
+	// "hel" and "p" are merged back into "help"
+	trie.Remove("hello")
+	if nodes := countNodes(trie.root); nodes != 2 {
+		t.Errorf("expected 2 nodes after removing hello, got %d", nodes)
+	}
+
+	// Removing the last key returns the tree to its root
+	trie.Remove("help")
+	if nodes := countNodes(trie.root); nodes != 1 {
+		t.Errorf("expected 1 node after removing every key, got %d", nodes)
+	}
+}
+
+func TestRemoveKeepsPrefixValues(t *testing.T) {
+	trie := NewTree[string]()
+	trie.Insert("app", "partial")
+	trie.Insert("apple", "complete")
+
+	trie.Remove("apple")
+	if nodes := countNodes(trie.root); nodes != 2 {
+		t.Errorf("expected 2 nodes, got %d", nodes)
+	}
+	value, found := trie.Find("app")
+	if !found || value != "partial" {
+		t.Errorf("expected found=true, value='partial', got found=%v, value=%v", found, value)
+	}
+
+	// Removing the shorter key keeps the longer one reachable
+	trie.Insert("apple", "complete")
+	trie.Remove("app")
+	value, found = trie.Find("apple")
+	if !found || value != "complete" {
+		t.Errorf("expected found=true, value='complete', got found=%v, value=%v", found, value)
+	}
+	if nodes := countNodes(trie.root); nodes != 2 {
+		t.Errorf("expected 2 nodes, got %d", nodes)
+	}
+}
+
+func TestRemoveMissingKeyDoesNotPrune(t *testing.T) {
+	trie := NewTree[string]()
+	trie.Insert("apple", "complete")
+
+	// "app" is on the path of "apple" but holds no value
+	if _, removed := trie.Remove("app"); removed {
+		t.Errorf("expected removed=false")
+	}
+	if nodes := countNodes(trie.root); nodes != 2 {
+		t.Errorf("expected 2 nodes, got %d", nodes)
+	}
+}
+
+func TestInsertRemoveCyclesReturnToBaseline(t *testing.T) {
+	trie := NewConcurrentTree[int]()
+	trie.Insert("keep", 0)
+	baseline := countNodes(trie.root)
+
+	for cycle := 0; cycle < 10; cycle++ {
+		for i := 0; i < 1000; i++ {
+			trie.Insert(fmt.Sprintf("session/%d/%d", cycle, i), i)
+		}
+		for i := 0; i < 1000; i++ {
+			trie.Remove(fmt.Sprintf("session/%d/%d", cycle, i))
+		}
+		if nodes := countNodes(trie.root); nodes != baseline {
+			t.Fatalf("cycle %d: expected %d nodes, got %d", cycle, baseline, nodes)
+		}
+	}
+}
+
+func TestCopiesShareContents(t *testing.T) {
+	t1 := NewTree[int]()
+	t2 := t1
+
+	// a change made through one copy is seen through the other
+	t2.Insert("a", 1)
+	if value, found := t1.Find("a"); !found || value != 1 || t1.Len() != 1 {
+		t.Errorf("expected a=1 and len=1 in the copy, got %d %v len=%d", value, found, t1.Len())
+	}
+	t2.RemovePrefix("")
+	if _, found := t1.Find("a"); found || t1.Len() != 0 {
+		t.Errorf("expected an empty copy, got len=%d", t1.Len())
+	}
+	t1.Insert("b", 2)
+	if stats := t2.Stats(); stats.Values != 1 || stats.Nodes != countNodes(t2.root) {
+		t.Errorf("expected the counters to be shared, got %+v", stats)
+	}
+}
+
 func BenchmarkInsert(b *testing.B) {
 	trie := NewTree[string]()
 	for n := 0; n < b.N; n++ {
@@ -444,6 +641,60 @@ func BenchmarkMixedOperationsThreadSafe(b *testing.B) {
 	}
 }
 
+func BenchmarkFindThreadSafeParallel(b *testing.B) {
+	trie := NewConcurrentTree[string]()
+	for n := 0; n < 1000; n++ {
+		trie.Insert(fmt.Sprint(n), "value")
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		n := 0
+		for pb.Next() {
+			trie.Find(fmt.Sprint(n % 1000))
+			n++
+		}
+	})
+}
+
+func BenchmarkMixedOperationsThreadSafeParallel(b *testing.B) {
+	trie := NewConcurrentTree[string]()
+	for n := 0; n < 1000; n++ {
+		trie.Insert(fmt.Sprint(n), "value")
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		n := 0
+		for pb.Next() {
+			trie.Insert(fmt.Sprint(n%1000), "newvalue")
+			trie.Find(fmt.Sprint(n % 1000))
+			trie.Remove(fmt.Sprint(n % 1000))
+			n++
+		}
+	})
+}
+
+func BenchmarkReadMostlyThreadSafeParallel(b *testing.B) {
+	trie := NewConcurrentTree[string]()
+	for n := 0; n < 1000; n++ {
+		trie.Insert(fmt.Sprint(n), "value")
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		n := 0
+		for pb.Next() {
+			if n%10 == 0 {
+				trie.Insert(fmt.Sprint(n%1000), "newvalue")
+			} else {
+				trie.Find(fmt.Sprint(n % 1000))
+			}
+			n++
+		}
+	})
+}
+
 func BenchmarkDeepTrieInsert(b *testing.B) {
 	trie := NewTree[string]()
 	key := "a"
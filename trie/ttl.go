@@ -0,0 +1,81 @@
+package trie
+
+import (
+	"time"
+)
+
+// NoExpiry is the remaining time TTL reports for a value that never expires.
+const NoExpiry time.Duration = -1
+
+// Touch sets the expiry time of the value stored under key to ttl from now, whether or not it had one before. A value with a
+// sliding expiry keeps sliding by its own duration on later lookups. It reports whether a non-expired value was found.
+func (t *Tree[T]) Touch(key string, ttl time.Duration) bool {
+	_, found := t.retime(key, func(_ *valueWithExpiry[T], now time.Time) *time.Time {
+		expiryTime := now.Add(ttl)
+		return &expiryTime
+	})
+	return found
+}
+
+// TTL returns how long the value stored under key has left before it expires, or NoExpiry if it never expires. It returns
+// false if the key does not exist or the value has expired, in which case the value is removed from the Trie. Unlike Find,
+// TTL does not push back a sliding expiry.
+func (t *Tree[T]) TTL(key string) (remaining time.Duration, found bool) {
+	entry, now := t.lookup(key)
+	if entry == nil {
+		return 0, false
+	}
+	if entry.expired(now) {
+		t.expire(key, entry)
+		return 0, false
+	}
+	if entry.expiry == nil {
+		return NoExpiry, true
+	}
+	return entry.expiry.Sub(now), true
+}
+
+// slide returns the value stored under key after pushing back its expiry time by its sliding duration.
+func (t *Tree[T]) slide(key string) (value T, found bool) {
+	return t.retime(key, func(entry *valueWithExpiry[T], now time.Time) *time.Time {
+		if entry.sliding <= 0 {
+			// the value has been replaced by one that does not slide since it was looked up
+			return entry.expiry
+		}
+		expiryTime := now.Add(entry.sliding)
+		return &expiryTime
+	})
+}
+
+// retime replaces the entry stored under key, if it has not expired, with one that holds the same value until the expiry time
+// returned by fn. It returns the value and whether it was found. Replacing an entry this way is not reported to the OnEvict
+// callback, while an expired entry is removed and reported as usual.
+func (t *Tree[T]) retime(key string, fn func(entry *valueWithExpiry[T], now time.Time) *time.Time) (value T, found bool) {
+	if t.syncSafe {
+		t.lock.Lock()
+	}
+	now := t.now()
+	var entry *valueWithExpiry[T]
+	if node := t.root.descend(key); node != nil && node.isEnd {
+		entry = node.value
+	}
+	if entry == nil || entry.expired(now) {
+		if t.syncSafe {
+			t.lock.Unlock()
+		}
+		if entry != nil {
+			t.expire(key, entry)
+		}
+		return *new(T), false
+	}
+	var target *node[T]
+	t.root, target, _ = t.root.insert(t.gen, key)
+	retimed := &valueWithExpiry[T]{value: entry.value, expiry: fn(entry, now), sliding: entry.sliding}
+	target.setEntry(retimed)
+	t.bound.noteExpiry(retimed)
+	t.touched(key)
+	if t.syncSafe {
+		t.lock.Unlock()
+	}
+	return entry.value, true
+}
@@ -0,0 +1,125 @@
+package trie
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSlidingExpiry(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
+	trie.InsertWithSlidingExpiry("session", "data", time.Minute)
+
+	// Every lookup pushes the expiry time back
+	for i := 0; i < 5; i++ {
+		clock.Advance(50 * time.Second)
+		if value, found := trie.Find("session"); !found || value != "data" {
+			t.Fatalf("expected found=true, value='data' after %d lookups, got found=%v, value=%v", i, found, value)
+		}
+	}
+
+	// TTL does not slide, so the entry expires a minute after the last Find
+	clock.Advance(30 * time.Second)
+	if remaining, found := trie.TTL("session"); !found || remaining != 30*time.Second {
+		t.Errorf("expected found=true, remaining=30s, got found=%v, remaining=%v", found, remaining)
+	}
+	clock.Advance(31 * time.Second)
+	if _, found := trie.Find("session"); found {
+		t.Errorf("expected session to expire")
+	}
+	if trie.Len() != 0 {
+		t.Errorf("expected len=0, got len=%d", trie.Len())
+	}
+}
+
+func TestSlidingExpiryDoesNotChangeSnapshots(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
+	trie.InsertWithSlidingExpiry("session", "data", time.Minute)
+	snapshot := trie.Snapshot()
+
+	clock.Advance(50 * time.Second)
+	trie.Find("session")
+	clock.Advance(50 * time.Second)
+	if _, found := snapshot.Find("session"); found {
+		t.Errorf("expected the snapshot to keep the original expiry time")
+	}
+	if _, found := trie.Find("session"); !found {
+		t.Errorf("expected the Tree to have pushed back the expiry time")
+	}
+}
+
+func TestSlidingExpiryIsNotAnEviction(t *testing.T) {
+	log := &evictionLog{}
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock), WithOnEvict(log.record))
+	trie.InsertWithSlidingExpiry("session", "data", time.Minute)
+	trie.Find("session")
+	trie.Touch("session", time.Hour)
+	if events := log.take(); len(events) != 0 {
+		t.Errorf("expected no evictions, got %v", events)
+	}
+
+	clock.Advance(2 * time.Hour)
+	trie.Find("session")
+	if events := log.take(); !reflect.DeepEqual(events, []string{"session=data:expired"}) {
+		t.Errorf("expected [session=data:expired], got %v", events)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
+	trie.InsertWithExpiry("key", "value", time.Minute)
+	trie.Insert("permanent", "value")
+
+	if trie.Touch("missing", time.Minute) {
+		t.Errorf("expected Touch to report a missing key")
+	}
+	if !trie.Touch("key", time.Hour) {
+		t.Errorf("expected Touch to find key")
+	}
+	clock.Advance(30 * time.Minute)
+	if _, found := trie.Find("key"); !found {
+		t.Errorf("expected Touch to extend the deadline")
+	}
+
+	// Touch gives a permanent value a deadline
+	trie.Touch("permanent", time.Minute)
+	clock.Advance(2 * time.Minute)
+	if _, found := trie.Find("permanent"); found {
+		t.Errorf("expected permanent to expire after Touch")
+	}
+
+	// An expired value cannot be touched back to life
+	clock.Advance(time.Hour)
+	if trie.Touch("key", time.Hour) {
+		t.Errorf("expected Touch to report an expired key")
+	}
+	if trie.Len() != 0 {
+		t.Errorf("expected len=0, got len=%d", trie.Len())
+	}
+}
+
+func TestInsertWithDeadline(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[string](WithClock(clock))
+	trie.InsertWithDeadline("key", "value", time.Unix(60, 0))
+	trie.Insert("permanent", "value")
+
+	if remaining, found := trie.TTL("key"); !found || remaining != time.Minute {
+		t.Errorf("expected found=true, remaining=1m, got found=%v, remaining=%v", found, remaining)
+	}
+	if remaining, found := trie.TTL("permanent"); !found || remaining != NoExpiry {
+		t.Errorf("expected found=true, remaining=NoExpiry, got found=%v, remaining=%v", found, remaining)
+	}
+	if _, found := trie.TTL("missing"); found {
+		t.Errorf("expected TTL to report a missing key")
+	}
+
+	clock.Set(time.Unix(61, 0))
+	if _, found := trie.TTL("key"); found {
+		t.Errorf("expected key to expire at its deadline")
+	}
+}
@@ -0,0 +1,128 @@
+package trie
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// EventType describes the change a WatchEvent reports.
+type EventType int
+
+const (
+	// EventPut means a value was stored under the key, either for the first time or in place of another value.
+	EventPut EventType = iota
+	// EventDelete means the value was removed from the key, with Remove, RemovePrefix, an atomic update or an eviction to
+	// keep the Tree within its capacity.
+	EventDelete
+	// EventExpire means the value had expired when it was removed, either lazily on Find, during a sweep, or when it was
+	// removed or overwritten.
+	EventExpire
+)
+
+// String returns the name of the event type.
+func (e EventType) String() string {
+	switch e {
+	case EventPut:
+		return "put"
+	case EventDelete:
+		return "delete"
+	case EventExpire:
+		return "expire"
+	default:
+		return "unknown"
+	}
+}
+
+// WatchEvent is a change to a key under a watched prefix. Value is the stored value for EventPut and the removed value
+// otherwise.
+type WatchEvent[T any] struct {
+	Type  EventType
+	Key   string
+	Value T
+	// Missed is the number of events dropped before this one because the watcher's buffer was full.
+	Missed int
+}
+
+// watchers are the watchers registered on a Tree.
+type watchers[T any] struct {
+	mu  sync.Mutex
+	all map[*watcher[T]]struct{}
+}
+
+// watcher is a channel receiving the changes under a prefix.
+type watcher[T any] struct {
+	prefix string
+	events chan WatchEvent[T]
+	missed int // events dropped since the last one delivered, guarded by the watchers' mutex
+}
+
+// Watch returns a channel receiving the changes to keys starting with prefix, in the order they are made, until ctx is done.
+// The channel is then closed. Events are sent while the Tree is locked for the change, so concurrent changes are received in
+// the order they were made. An overwrite is a single EventPut, and replacing the contents with ReadFrom is not reported.
+//
+// The channel buffers up to buffer events. Watchers never slow down or block the Tree: when a watcher's buffer is full,
+// the new event is dropped for that watcher, and the number of events it missed is reported in the Missed field of the next
+// event it receives, so it can tell that it has to read the keys it watches again. It panics if buffer is less than 1.
+func (t *Tree[T]) Watch(ctx context.Context, prefix string, buffer int) <-chan WatchEvent[T] {
+	if buffer < 1 {
+		panic(fmt.Sprintf("trie: watch buffer must be at least 1, got %d", buffer))
+	}
+	w := &watcher[T]{prefix: prefix, events: make(chan WatchEvent[T], buffer)}
+	if t.syncSafe {
+		t.lock.Lock()
+	}
+	if t.watch == nil {
+		t.watch = &watchers[T]{all: make(map[*watcher[T]]struct{})}
+	}
+	ws := t.watch
+	if t.syncSafe {
+		t.lock.Unlock()
+	}
+	ws.mu.Lock()
+	ws.all[w] = struct{}{}
+	ws.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		ws.mu.Lock()
+		delete(ws.all, w)
+		close(w.events)
+		ws.mu.Unlock()
+	}()
+	return w.events
+}
+
+// notify sends an event to the watchers of key without blocking. It must be called while holding the write lock.
+func (t *Tree[T]) notify(kind EventType, key string, value T) {
+	if t.watch == nil {
+		return
+	}
+	t.watch.mu.Lock()
+	defer t.watch.mu.Unlock()
+	for w := range t.watch.all {
+		if !strings.HasPrefix(key, w.prefix) {
+			continue
+		}
+		select {
+		case w.events <- WatchEvent[T]{Type: kind, Key: key, Value: value, Missed: w.missed}:
+			w.missed = 0
+		default:
+			w.missed++
+		}
+	}
+}
+
+// notifyRemoved tells the watchers of key that old has been removed from it, as an EventExpire if old had expired. It must be
+// called while holding the write lock.
+func (t *Tree[T]) notifyRemoved(key string, old *valueWithExpiry[T]) {
+	if t.watch == nil {
+		return
+	}
+	kind := EventDelete
+	if old.expired(t.now()) {
+		kind = EventExpire
+	}
+	t.notify(kind, key, old.value)
+}
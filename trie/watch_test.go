@@ -0,0 +1,171 @@
+package trie
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receive reads the events buffered in events without waiting for more.
+func receive[T any](events <-chan WatchEvent[T]) []WatchEvent[T] {
+	var received []WatchEvent[T]
+	for {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		default:
+			return received
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewConcurrentTree[string](WithClock(clock))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := trie.Watch(ctx, "service/foo/", 16)
+
+	trie.Insert("service/foo/port", "80")
+	trie.Insert("service/bar/port", "81")
+	trie.Insert("service/foo/port", "8080")
+	trie.Remove("service/foo/port")
+	trie.InsertWithExpiry("service/foo/token", "secret", time.Minute)
+	clock.Advance(2 * time.Minute)
+	trie.Find("service/foo/token")
+
+	expected := []WatchEvent[string]{
+		{Type: EventPut, Key: "service/foo/port", Value: "80"},
+		{Type: EventPut, Key: "service/foo/port", Value: "8080"},
+		{Type: EventDelete, Key: "service/foo/port", Value: "8080"},
+		{Type: EventPut, Key: "service/foo/token", Value: "secret"},
+		{Type: EventExpire, Key: "service/foo/token", Value: "secret"},
+	}
+	if received := receive(events); !reflect.DeepEqual(received, expected) {
+		t.Errorf("expected %v, got %v", expected, received)
+	}
+}
+
+func TestWatchBulkChanges(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	trie := NewTree[int](WithClock(clock), WithMaxEntries(3))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := trie.Watch(ctx, "", 16)
+
+	// atomic updates
+	trie.GetOrInsert("a", 1)
+	trie.CompareAndSwap("a", 1, 2, func(a, b int) bool { return a == b })
+	trie.Update("a", func(old int, exists bool) (int, bool) { return 0, false })
+	// evictions to stay within capacity
+	for i, key := range []string{"k1", "k2", "k3", "k4"} {
+		trie.Insert(key, i)
+	}
+	// sweeps and prefix removals
+	trie.InsertWithExpiry("k5", 5, time.Second)
+	clock.Advance(time.Minute)
+	trie.Sweep()
+	trie.RemovePrefix("k")
+
+	var got []string
+	for _, event := range receive(events) {
+		got = append(got, fmt.Sprintf("%s %s=%d", event.Type, event.Key, event.Value))
+	}
+	expected := []string{
+		"put a=1", "put a=2", "delete a=2",
+		"put k1=0", "put k2=1", "put k3=2", "delete k1=0", "put k4=3",
+		"delete k2=1", "put k5=5",
+		"expire k5=5",
+		"delete k3=2", "delete k4=3",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestWatchOverflow(t *testing.T) {
+	trie := NewTree[int]()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := trie.Watch(ctx, "", 2)
+
+	// the buffer holds 2 events, the next 3 are dropped
+	for i := 0; i < 5; i++ {
+		trie.Insert("key", i)
+	}
+	received := receive(events)
+	if len(received) != 2 || received[0].Value != 0 || received[1].Value != 1 || received[1].Missed != 0 {
+		t.Fatalf("expected the first 2 events without misses, got %v", received)
+	}
+
+	// the next event delivered reports the dropped ones
+	trie.Insert("key", 5)
+	received = receive(events)
+	if len(received) != 1 || received[0].Value != 5 || received[0].Missed != 3 {
+		t.Errorf("expected an event reporting 3 missed events, got %v", received)
+	}
+	trie.Insert("key", 6)
+	if received := receive(events); len(received) != 1 || received[0].Missed != 0 {
+		t.Errorf("expected the miss count to be reset, got %v", received)
+	}
+}
+
+func TestWatchCancel(t *testing.T) {
+	trie := NewConcurrentTree[int]()
+	ctx, cancel := context.WithCancel(context.Background())
+	events := trie.Watch(ctx, "", 1)
+	other := trie.Watch(context.Background(), "", 1)
+
+	cancel()
+	// the channel is closed once the watcher is unregistered
+	for range events {
+	}
+	trie.Insert("key", 1)
+	if received := receive(other); len(received) != 1 {
+		t.Errorf("expected the other watcher to keep receiving, got %v", received)
+	}
+	if len(trie.watch.all) != 1 {
+		t.Errorf("expected 1 remaining watcher, got %d", len(trie.watch.all))
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a buffer of 0 to panic")
+		}
+	}()
+	trie.Watch(context.Background(), "", 0)
+}
+
+func TestWatchConcurrent(t *testing.T) {
+	trie := NewConcurrentTree[int]()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := trie.Watch(ctx, "", 4000)
+
+	// events for a key are received in the order of its changes
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				trie.Insert(fmt.Sprintf("g%d", g), i)
+			}
+		}(g)
+	}
+	wg.Wait()
+	last := map[string]int{}
+	received := receive(events)
+	for _, event := range received {
+		if previous, ok := last[event.Key]; ok && event.Value != previous+1 {
+			t.Fatalf("%s: expected %d after %d, got %d", event.Key, previous+1, previous, event.Value)
+		}
+		last[event.Key] = event.Value
+	}
+	if len(received) != 4000 {
+		t.Errorf("expected 4000 events, got %d", len(received))
+	}
+}